@@ -0,0 +1,223 @@
+// Command exportusers streams users from the ExportUsers RPC into a CSV or
+// JSONL file. With -checkpoint-file an interrupted export picks up where it
+// stopped and appends to the same output.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	authpb "auth-microservice/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// checkpointEvery is how many users are written between checkpoint saves.
+const checkpointEvery = 1000
+
+type row struct {
+	ID                 string   `json:"id"`
+	Name               string   `json:"name"`
+	Email              string   `json:"email"`
+	Roles              []string `json:"roles"`
+	EmailVerified      bool     `json:"email_verified"`
+	MustChangePassword bool     `json:"must_change_password"`
+	Suspended          bool     `json:"suspended"`
+	Deleted            bool     `json:"deleted"`
+	CreatedAt          string   `json:"created_at,omitempty"`
+	PasswordChangedAt  string   `json:"password_changed_at,omitempty"`
+}
+
+var csvHeader = []string{"id", "name", "email", "roles", "email_verified", "must_change_password", "suspended", "deleted", "created_at", "password_changed_at"}
+
+func (r row) csv() []string {
+	return []string{
+		r.ID, r.Name, r.Email, strings.Join(r.Roles, ";"),
+		strconv.FormatBool(r.EmailVerified), strconv.FormatBool(r.MustChangePassword),
+		strconv.FormatBool(r.Suspended), strconv.FormatBool(r.Deleted),
+		r.CreatedAt, r.PasswordChangedAt,
+	}
+}
+
+func formatTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().UTC().Format(time.RFC3339)
+}
+
+func toRow(u *authpb.User) row {
+	return row{
+		ID:                 u.Id,
+		Name:               u.Name,
+		Email:              u.Email,
+		Roles:              u.Roles,
+		EmailVerified:      u.EmailVerified,
+		MustChangePassword: u.MustChangePassword,
+		Suspended:          u.Suspended,
+		Deleted:            u.Deleted,
+		CreatedAt:          formatTime(u.CreatedAt),
+		PasswordChangedAt:  formatTime(u.PasswordChangedAt),
+	}
+}
+
+func parseTime(flagName, value string) *timestamppb.Timestamp {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Fatalf("-%s must be an RFC 3339 time: %v", flagName, err)
+	}
+	return timestamppb.New(t)
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:50051", "address of the auth service")
+	token := flag.String("token", os.Getenv("AUTH_TOKEN"), "admin bearer token (defaults to $AUTH_TOKEN)")
+	format := flag.String("format", "csv", "output format: csv or jsonl")
+	out := flag.String("out", "", "output file (defaults to stdout)")
+	checkpointFile := flag.String("checkpoint-file", "", "file that stores the export checkpoint so an interrupted export can resume")
+	name := flag.String("name", "", "only export users with this name")
+	email := flag.String("email", "", "only export the user with this email")
+	role := flag.String("role", "", "only export users holding this role")
+	includeDeleted := flag.Bool("include-deleted", false, "include deleted accounts")
+	createdAfter := flag.String("created-after", "", "only export users created at or after this RFC 3339 time")
+	createdBefore := flag.String("created-before", "", "only export users created before this RFC 3339 time")
+	flag.Parse()
+
+	if *format != "csv" && *format != "jsonl" {
+		log.Fatalf("-format must be csv or jsonl")
+	}
+	if *token == "" {
+		log.Fatalf("an admin token is required, pass -token or set AUTH_TOKEN")
+	}
+	if *checkpointFile != "" && *out == "" {
+		log.Fatalf("-checkpoint-file needs -out, a resumed export is appended to the same file")
+	}
+
+	checkpoint := ""
+	if *checkpointFile != "" {
+		data, err := os.ReadFile(*checkpointFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Fatalf("reading checkpoint: %v", err)
+		}
+		checkpoint = strings.TrimSpace(string(data))
+	}
+	resuming := checkpoint != ""
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if resuming {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(*out, flags, 0o644)
+		if err != nil {
+			log.Fatalf("opening output: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	buf := bufio.NewWriter(w)
+	defer buf.Flush()
+
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("connecting to %s: %v", *addr, err)
+	}
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+*token)
+	stream, err := authpb.NewAuthServiceClient(conn).ExportUsers(ctx, &authpb.ExportUsersRequest{
+		Name:           *name,
+		Email:          *email,
+		Role:           *role,
+		IncludeDeleted: *includeDeleted,
+		CreatedAfter:   parseTime("created-after", *createdAfter),
+		CreatedBefore:  parseTime("created-before", *createdBefore),
+		Checkpoint:     checkpoint,
+	})
+	if err != nil {
+		log.Fatalf("starting export: %v", err)
+	}
+
+	csvWriter := csv.NewWriter(buf)
+	jsonEncoder := json.NewEncoder(buf)
+	if *format == "csv" && !resuming {
+		if err := csvWriter.Write(csvHeader); err != nil {
+			log.Fatalf("writing output: %v", err)
+		}
+	}
+
+	// saveCheckpoint flushes the output first so the checkpoint never points
+	// past what is on disk.
+	saveCheckpoint := func() {
+		if *checkpointFile == "" || checkpoint == "" {
+			return
+		}
+		csvWriter.Flush()
+		if err := buf.Flush(); err != nil {
+			log.Fatalf("writing output: %v", err)
+		}
+		if err := os.WriteFile(*checkpointFile, []byte(checkpoint+"\n"), 0o644); err != nil {
+			log.Fatalf("saving checkpoint: %v", err)
+		}
+	}
+
+	count := 0
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			saveCheckpoint()
+			log.Fatalf("export interrupted after %d users: %v", count, err)
+		}
+
+		r := toRow(resp.User)
+		if *format == "csv" {
+			err = csvWriter.Write(r.csv())
+		} else {
+			err = jsonEncoder.Encode(r)
+		}
+		if err != nil {
+			log.Fatalf("writing output: %v", err)
+		}
+
+		checkpoint = resp.Checkpoint
+		count++
+		if count%checkpointEvery == 0 {
+			saveCheckpoint()
+		}
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		log.Fatalf("writing output: %v", err)
+	}
+	if err := buf.Flush(); err != nil {
+		log.Fatalf("writing output: %v", err)
+	}
+	if *checkpointFile != "" {
+		if err := os.Remove(*checkpointFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("removing checkpoint: %v", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "exported %d users\n", count)
+}
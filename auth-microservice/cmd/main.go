@@ -6,13 +6,22 @@ import (
 	"log"
 	"net"
 	"os"
+	"time"
 
+	"auth-microservice/internal/breach"
+	"auth-microservice/internal/config"
 	"auth-microservice/internal/db"
 	"auth-microservice/internal/handler"
+	"auth-microservice/internal/mailer"
 	"auth-microservice/internal/middleware"
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/passwordpolicy"
+	"auth-microservice/internal/repository"
 
 	"auth-microservice/internal/redis"
 	"auth-microservice/internal/service"
+	"auth-microservice/internal/utils"
+	"auth-microservice/pkg/authz"
 	authpb "auth-microservice/proto"
 
 	"github.com/joho/godotenv"
@@ -35,13 +44,53 @@ func main() {
 		log.Fatalf("MongoDB connection error: %v", err)
 	}
 
-	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(middleware.AuthInterceptor),
-	)
+	if err := repository.RunMigrations(context.Background(), config.Duration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour)); err != nil {
+		log.Fatalf("MongoDB migration error: %v", err)
+	}
+
+	if err := repository.EnsureIndexes(context.Background()); err != nil {
+		log.Fatalf("MongoDB index setup error: %v", err)
+	}
+
+	hasher, err := utils.NewPasswordHasherFromEnv()
+	if err != nil {
+		log.Fatalf("Password hasher configuration error: %v", err)
+	}
+
+	passwordPolicy, err := passwordpolicy.LoadFromEnv()
+	if err != nil {
+		log.Fatalf("Password policy configuration error: %v", err)
+	}
+	passwordPolicy.MaxBytes = hasher.MaxPasswordBytes()
+	if passwordPolicy.MaxBytes > 0 && passwordPolicy.MinLength > passwordPolicy.MaxBytes {
+		log.Fatalf("Password policy configuration error: PASSWORD_MIN_LENGTH %d exceeds the %d bytes the password hasher accepts", passwordPolicy.MinLength, passwordPolicy.MaxBytes)
+	}
 
-	authService := service.NewAuthService()
+	authService := service.NewAuthService(service.Options{
+		Mailer:         mailer.NewFromEnv(),
+		Hasher:         hasher,
+		BreachChecker:  breach.NewFromEnv(),
+		PasswordPolicy: passwordPolicy,
+	})
 	authHandler := handler.NewAuthServiceHandler(authService)
 
+	go runAccountPurger(authService, config.Duration("ACCOUNT_PURGE_INTERVAL", time.Hour))
+
+	policies, err := authz.LoadPolicies(model.IsPermission, &authpb.AuthService_ServiceDesc)
+	if err != nil {
+		log.Fatalf("Authorization policy error: %v", err)
+	}
+	authorizer := authz.New(authz.Options{
+		Policies:    policies,
+		Sessions:    middleware.Sessions{},
+		Permissions: middleware.RolePermissions{},
+		Acceptance:  middleware.PolicyAcceptance{},
+	})
+
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(authorizer.Unary),
+		grpc.StreamInterceptor(authorizer.Stream),
+	)
 	authpb.RegisterAuthServiceServer(grpcServer, authHandler)
 
 	lis, err := net.Listen("tcp", ":50051")
@@ -54,3 +103,24 @@ func main() {
 		log.Fatalf("Failed to serve: %v", err)
 	}
 }
+
+// runAccountPurger periodically purges deleted accounts whose grace period
+// has ended.
+func runAccountPurger(authService service.AuthService, interval time.Duration) {
+	if interval <= 0 {
+		log.Printf("ACCOUNT_PURGE_INTERVAL must be positive, got %s; using 1h", interval)
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; ; <-ticker.C {
+		purged, err := authService.PurgeExpiredAccounts(context.Background())
+		if err != nil {
+			log.Printf("account purge failed: %v", err)
+		}
+		if purged > 0 {
+			log.Printf("purged %d deleted accounts", purged)
+		}
+	}
+}
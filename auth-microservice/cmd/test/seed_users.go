@@ -22,7 +22,7 @@ func generateRandomEmail(index int) string {
 	return fmt.Sprintf("user%d@example.com", index)
 }
 
-func generateRandomUsername(index int) string {
+func generateRandomName(index int) string {
 	return fmt.Sprintf("user%d", index)
 }
 
@@ -31,12 +31,18 @@ func generateUsers(count int, startIndex int) []interface{} {
 	password := hashPassword("password123")
 
 	for i := 0; i < count; i++ {
+		now := time.Now()
 		user := bson.M{
-			"username":     generateRandomUsername(startIndex + i),
-			"email":        generateRandomEmail(startIndex + i),
-			"password":     password,
-			"is_active":    true,
-			"created_date": time.Now(),
+			"name":                 generateRandomName(startIndex + i),
+			"email":                generateRandomEmail(startIndex + i),
+			"email_verified":       true,
+			"email_verified_at":    now,
+			"roles":                []string{"user"},
+			"password":             password,
+			"password_changed_at":  now,
+			"must_change_password": false,
+			"deleted":              false,
+			"created_at":           now,
 		}
 		users = append(users, user)
 	}
@@ -0,0 +1,119 @@
+package breach
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"auth-microservice/internal/config"
+)
+
+// Checker reports how many times a password appears in a breach corpus.
+// Implementations only ever see the first five hex characters of the
+// password's SHA-1 hash outside the process (k-anonymity).
+type Checker interface {
+	Count(ctx context.Context, password string) (int, error)
+}
+
+// NewFromEnv returns a checker backed by BREACH_CORPUS_DIR when set, or by the
+// range API at BREACH_API_URL. It returns nil when screening is disabled.
+func NewFromEnv() Checker {
+	if dir := config.String("BREACH_CORPUS_DIR", ""); dir != "" {
+		return &DirectoryChecker{Dir: dir}
+	}
+	if baseURL := config.String("BREACH_API_URL", ""); baseURL != "" {
+		return &RangeClient{
+			BaseURL: baseURL,
+			HTTP:    &http.Client{Timeout: config.Duration("BREACH_API_TIMEOUT", 3*time.Second)},
+		}
+	}
+	return nil
+}
+
+func hashPassword(password string) (prefix, suffix string) {
+	sum := sha1.Sum([]byte(password))
+	h := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return h[:5], h[5:]
+}
+
+// DirectoryChecker reads a range-partitioned corpus in the HIBP format: one
+// file per 5-character hash prefix (e.g. "21BD1.txt") whose lines are
+// "SUFFIX:COUNT".
+type DirectoryChecker struct {
+	Dir string
+}
+
+func (c *DirectoryChecker) Count(ctx context.Context, password string) (int, error) {
+	prefix, suffix := hashPassword(password)
+
+	f, err := os.Open(filepath.Join(c.Dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = os.Open(filepath.Join(c.Dir, prefix))
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return findSuffix(f, suffix)
+}
+
+// RangeClient queries an HTTP range API compatible with
+// https://api.pwnedpasswords.com/range/{prefix}.
+type RangeClient struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+func (c *RangeClient) Count(ctx context.Context, password string) (int, error) {
+	prefix, suffix := hashPassword(password)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.BaseURL, "/")+"/range/"+prefix, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("breach range api returned %s", resp.Status)
+	}
+
+	return findSuffix(resp.Body, suffix)
+}
+
+func findSuffix(r io.Reader, suffix string) (int, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lineSuffix, count, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil {
+			return 0, fmt.Errorf("malformed breach corpus line %q", line)
+		}
+		// Padding entries returned by the range API have a count of zero.
+		return n, nil
+	}
+	return 0, scanner.Err()
+}
@@ -1,9 +1,11 @@
 package db
 
 import (
+	"auth-microservice/internal/config"
 	"auth-microservice/internal/model"
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -17,13 +19,18 @@ import (
 var Client *mongo.Client
 
 var (
-	DB_NAME         string
-	USER_COLLECTION string
+	DB_NAME               string
+	USER_COLLECTION       string
+	PASSWORD_HISTORY_SIZE int
 )
 
 func InitMongoDB(uri string) error {
 	DB_NAME = os.Getenv("DB_NAME")
 	USER_COLLECTION = os.Getenv("USER_COLLECTION")
+	PASSWORD_HISTORY_SIZE = config.Int("PASSWORD_HISTORY_SIZE", 5)
+	if PASSWORD_HISTORY_SIZE < 0 {
+		return fmt.Errorf("PASSWORD_HISTORY_SIZE must not be negative, got %d", PASSWORD_HISTORY_SIZE)
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -92,11 +99,50 @@ func FindUsers(ctx context.Context, filter model.UserFilter) ([]model.User, int6
 	return users, total, nil
 }
 
+// UpdatePassword sets a new password hash and moves the current one into the
+// user's password history, keeping at most PASSWORD_HISTORY_SIZE entries.
 func UpdatePassword(ctx context.Context, userID primitive.ObjectID, hashedPassword string) error {
+	return setPassword(ctx, userID, bson.M{
+		"password":             hashedPassword,
+		"password_changed_at":  time.Now(),
+		"must_change_password": false,
+	})
+}
+
+// InvalidatePassword clears the password hash so the account cannot log in
+// until the password is reset. The old hash is kept in the history.
+func InvalidatePassword(ctx context.Context, userID, forcedBy primitive.ObjectID) error {
+	return setPassword(ctx, userID, bson.M{
+		"password":                 "",
+		"must_change_password":     true,
+		"password_reset_forced_by": forcedBy,
+		"password_reset_forced_at": time.Now(),
+	})
+}
+
+func setPassword(ctx context.Context, userID primitive.ObjectID, fields bson.M) error {
 	collection := GetUserCollection()
 
+	var history interface{} = bson.A{}
+	if PASSWORD_HISTORY_SIZE > 0 {
+		history = bson.M{"$slice": bson.A{
+			bson.M{"$concatArrays": bson.A{
+				bson.M{"$cond": bson.A{
+					bson.M{"$gt": bson.A{bson.M{"$ifNull": bson.A{"$password", ""}}, ""}},
+					bson.A{"$password"},
+					bson.A{},
+				}},
+				bson.M{"$ifNull": bson.A{"$password_history", bson.A{}}},
+			}},
+			PASSWORD_HISTORY_SIZE,
+		}}
+	}
+	fields["password_history"] = history
+
 	filter := bson.M{"_id": userID}
-	update := bson.M{"$set": bson.M{"password": hashedPassword}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: fields}},
+	}
 
 	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
@@ -109,3 +155,15 @@ func UpdatePassword(ctx context.Context, userID primitive.ObjectID, hashedPasswo
 
 	return nil
 }
+
+// ReplacePasswordHash swaps the stored hash for an equivalent one, e.g. after
+// upgrading the hashing parameters. The password history is left untouched.
+func ReplacePasswordHash(ctx context.Context, userID primitive.ObjectID, oldHash, newHash string) error {
+	collection := GetUserCollection()
+
+	filter := bson.M{"_id": userID, "password": oldHash}
+	update := bson.M{"$set": bson.M{"password": newHash}}
+
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
@@ -3,17 +3,25 @@ package handler
 import (
 	"auth-microservice/internal/service"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"strings"
+	"time"
 
 	authpb "auth-microservice/proto"
 
 	"auth-microservice/internal/model"
+	"auth-microservice/pkg/authz"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type AuthServiceHandler struct {
@@ -26,6 +34,11 @@ func NewAuthServiceHandler(authService service.AuthService) *AuthServiceHandler
 }
 
 func grpcErrorFromService(err error) error {
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationStatus(validationErr)
+	}
+
 	switch err {
 	case nil:
 		return nil
@@ -39,20 +52,66 @@ func grpcErrorFromService(err error) error {
 		return status.Error(codes.NotFound, err.Error())
 	case service.ErrInvalidArgument:
 		return status.Error(codes.InvalidArgument, err.Error())
+	case service.ErrEmailNotVerified:
+		return statusWithReason(codes.FailedPrecondition, err, "EMAIL_NOT_VERIFIED")
+	case service.ErrPasswordReused:
+		return statusWithReason(codes.FailedPrecondition, err, "PASSWORD_REUSED")
+	case service.ErrAccountSuspended:
+		return statusWithReason(codes.PermissionDenied, err, "ACCOUNT_SUSPENDED")
+	case service.ErrAccountLocked:
+		return statusWithReason(codes.PermissionDenied, err, "ACCOUNT_LOCKED")
+	case service.ErrLastAdmin:
+		return statusWithReason(codes.FailedPrecondition, err, "LAST_ADMIN")
+	case service.ErrRoleInUse:
+		return statusWithReason(codes.FailedPrecondition, err, "ROLE_IN_USE")
 	default:
 		return status.Error(codes.Internal, err.Error())
 	}
 }
 
+func validationStatus(err *service.ValidationError) error {
+	st := status.New(codes.InvalidArgument, err.Error())
+
+	badRequest := &errdetails.BadRequest{}
+	for _, v := range err.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Reason:      v.Reason,
+			Description: v.Description,
+		})
+	}
+
+	withDetails, detailsErr := st.WithDetails(badRequest)
+	if detailsErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// statusWithReason attaches a machine-readable reason so clients can tell
+// errors that share a gRPC code apart.
+func statusWithReason(code codes.Code, err error, reason string) error {
+	st := status.New(code, err.Error())
+	withDetails, detailsErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: "auth",
+	})
+	if detailsErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
 func (s *AuthServiceHandler) Register(ctx context.Context, req *authpb.RegisterRequest) (*authpb.RegisterResponse, error) {
 	user := &model.User{
-		Email:    req.Email,
-		Password: req.Password,
-		Name:     req.Name,
-		Role:     "user",
+		Email:      req.Email,
+		Password:   req.Password,
+		Name:       req.Name,
+		Roles:      []string{model.RoleUser},
+		Attributes: attributesFromProto(req.Attributes),
 	}
 
-	err := s.authService.Register(ctx, user)
+	err := s.authService.Register(ctx, user, policyVersionsFromProto(req.AcceptedPolicies))
 	if err != nil {
 		log.Printf("Register failed: %v", err)
 		return nil, grpcErrorFromService(err)
@@ -61,19 +120,21 @@ func (s *AuthServiceHandler) Register(ctx context.Context, req *authpb.RegisterR
 	return &authpb.RegisterResponse{
 		Id:    user.ID.Hex(),
 		Email: user.Email,
-		Role:  user.Role,
+		Roles: user.Roles,
 	}, nil
 }
 
 func (s *AuthServiceHandler) Login(ctx context.Context, req *authpb.LoginRequest) (*authpb.LoginResponse, error) {
-	token, err := s.authService.Login(ctx, req.Email, req.Password)
+	result, err := s.authService.Login(ctx, req.Email, req.Password)
 	if err != nil {
 		log.Printf("Login failed: %v", err)
 		return nil, grpcErrorFromService(err)
 	}
 	log.Printf("Login success")
 	return &authpb.LoginResponse{
-		Token: token,
+		Token:                     result.Token,
+		PasswordChangeRequired:    result.PasswordChangeRequired,
+		EmailVerificationRequired: result.EmailVerificationRequired,
 	}, nil
 }
 
@@ -108,33 +169,53 @@ func (s *AuthServiceHandler) Logout(ctx context.Context, req *authpb.LogoutReque
 }
 
 func (s *AuthServiceHandler) GetUserByID(ctx context.Context, req *authpb.GetUserByIDRequest) (*authpb.GetUserByIDResponse, error) {
+	callerID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
 	objectID, err := primitive.ObjectIDFromHex(req.Id)
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid user id")
 	}
 
-	user, err := s.authService.GetUserByID(ctx, objectID)
+	user, err := s.authService.ViewUser(ctx, callerID, objectID)
 	if err != nil {
 		return nil, grpcErrorFromService(err)
 	}
 
 	return &authpb.GetUserByIDResponse{
-		Id:    user.ID.Hex(),
-		Name:  user.Name,
-		Email: user.Email,
-		Role:  user.Role,
+		Id:         user.ID.Hex(),
+		Name:       user.Name,
+		Email:      user.Email,
+		Attributes: attributesToProto(user.Attributes),
+		Roles:      user.Roles,
 	}, nil
 }
 
 func (s *AuthServiceHandler) AddRole(ctx context.Context, req *authpb.AddRoleRequest) (*authpb.AddRoleResponse, error) {
-	adminUserIDHex, ok := ctx.Value("user_id").(string)
-	if !ok || adminUserIDHex == "" {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
 		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
 	}
 
-	adminUserID, err := primitive.ObjectIDFromHex(adminUserIDHex)
+	targetUserID, err := primitive.ObjectIDFromHex(req.TargetUserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	err = s.authService.AddRole(ctx, adminUserID, targetUserID, req.NewRole)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "invalid admin user id")
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.AddRoleResponse{Success: true}, nil
+}
+
+func (s *AuthServiceHandler) RemoveRole(ctx context.Context, req *authpb.RemoveRoleRequest) (*authpb.RemoveRoleResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
 	}
 
 	targetUserID, err := primitive.ObjectIDFromHex(req.TargetUserId)
@@ -142,12 +223,31 @@ func (s *AuthServiceHandler) AddRole(ctx context.Context, req *authpb.AddRoleReq
 		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
 	}
 
-	err = s.authService.AddRole(ctx, adminUserID, targetUserID, req.NewRole)
+	err = s.authService.RemoveRole(ctx, adminUserID, targetUserID, req.Role)
 	if err != nil {
 		return nil, grpcErrorFromService(err)
 	}
 
-	return &authpb.AddRoleResponse{Success: true}, nil
+	return &authpb.RemoveRoleResponse{Success: true}, nil
+}
+
+func (s *AuthServiceHandler) UpdateUserRole(ctx context.Context, req *authpb.UpdateUserRoleRequest) (*authpb.UpdateUserRoleResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	user, err := s.authService.UpdateUserRoles(ctx, adminUserID, targetUserID, req.Roles)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.UpdateUserRoleResponse{Success: true, User: userToProto(user)}, nil
 }
 
 func (s *AuthServiceHandler) ListUsers(ctx context.Context, req *authpb.ListUsersRequest) (*authpb.ListUsersResponse, error) {
@@ -157,6 +257,12 @@ func (s *AuthServiceHandler) ListUsers(ctx context.Context, req *authpb.ListUser
 		Page:  req.Page,
 		Limit: req.Limit,
 	}
+	if len(req.Attributes) > 0 {
+		filter.Attributes = make(map[string]interface{}, len(req.Attributes))
+		for name, value := range req.Attributes {
+			filter.Attributes[name] = value
+		}
+	}
 
 	users, total, err := s.authService.ListUsers(ctx, filter)
 	if err != nil {
@@ -165,12 +271,7 @@ func (s *AuthServiceHandler) ListUsers(ctx context.Context, req *authpb.ListUser
 
 	var userProtos []*authpb.User
 	for _, u := range users {
-		userProtos = append(userProtos, &authpb.User{
-			Id:    u.ID.Hex(),
-			Name:  u.Name,
-			Email: u.Email,
-			Role:  u.Role,
-		})
+		userProtos = append(userProtos, userToProto(u))
 	}
 
 	return &authpb.ListUsersResponse{
@@ -180,36 +281,29 @@ func (s *AuthServiceHandler) ListUsers(ctx context.Context, req *authpb.ListUser
 }
 
 func (s *AuthServiceHandler) UpdateProfile(ctx context.Context, req *authpb.UpdateProfileRequest) (*authpb.UpdateProfileResponse, error) {
-	userIDHex, ok := ctx.Value("user_id").(string)
-	if !ok || userIDHex == "" {
+	userID, ok := authz.UserID(ctx)
+	if !ok {
 		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDHex)
-	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "invalid user id")
-	}
-
-	err = s.authService.UpdateProfile(ctx, userID, req.Name, req.Email)
+	emailChangePending, err := s.authService.UpdateProfile(ctx, userID, req.Name, req.Email, attributesFromProto(req.Attributes))
 	if err != nil {
 		return nil, grpcErrorFromService(err)
 	}
 
-	return &authpb.UpdateProfileResponse{Success: true}, nil
+	return &authpb.UpdateProfileResponse{
+		Success:            true,
+		EmailChangePending: emailChangePending,
+	}, nil
 }
 
 func (s *AuthServiceHandler) DeleteProfile(ctx context.Context, req *authpb.DeleteProfileRequest) (*authpb.DeleteProfileResponse, error) {
-	userIDHex, ok := ctx.Value("user_id").(string)
-	if !ok || userIDHex == "" {
+	userID, ok := authz.UserID(ctx)
+	if !ok {
 		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDHex)
-	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "invalid user id")
-	}
-
-	err = s.authService.DeleteProfile(ctx, userID)
+	err := s.authService.DeleteProfile(ctx, userID)
 	if err != nil {
 		return nil, grpcErrorFromService(err)
 	}
@@ -218,23 +312,27 @@ func (s *AuthServiceHandler) DeleteProfile(ctx context.Context, req *authpb.Dele
 }
 
 func (s *AuthServiceHandler) GeneratePasswordResetToken(ctx context.Context, req *authpb.GeneratePasswordResetTokenRequest) (*authpb.GeneratePasswordResetTokenResponse, error) {
-	userIDHex, ok := ctx.Value("user_id").(string)
-	if !ok || userIDHex == "" {
+	userID, ok := authz.UserID(ctx)
+	if !ok {
 		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDHex)
+	err := s.authService.GeneratePasswordResetToken(ctx, userID)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "invalid user id")
+		return nil, grpcErrorFromService(err)
 	}
 
-	token, err := s.authService.GeneratePasswordResetToken(ctx, userID)
+	return &authpb.GeneratePasswordResetTokenResponse{Success: true}, nil
+}
+
+func (s *AuthServiceHandler) RequestPasswordReset(ctx context.Context, req *authpb.RequestPasswordResetRequest) (*authpb.RequestPasswordResetResponse, error) {
+	err := s.authService.RequestPasswordReset(ctx, req.Email)
 	if err != nil {
 		return nil, grpcErrorFromService(err)
 	}
 
-	return &authpb.GeneratePasswordResetTokenResponse{
-		ResetToken: token,
+	return &authpb.RequestPasswordResetResponse{
+		Success: true,
 	}, nil
 }
 
@@ -248,3 +346,977 @@ func (s *AuthServiceHandler) ResetPassword(ctx context.Context, req *authpb.Rese
 		Success: true,
 	}, nil
 }
+
+func (s *AuthServiceHandler) ChangePassword(ctx context.Context, req *authpb.ChangePasswordRequest) (*authpb.ChangePasswordResponse, error) {
+	userID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	sessionID, ok := authz.SessionID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing session id in context")
+	}
+
+	err := s.authService.ChangePassword(ctx, userID, sessionID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.ChangePasswordResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) ForcePasswordReset(ctx context.Context, req *authpb.ForcePasswordResetRequest) (*authpb.ForcePasswordResetResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	err = s.authService.ForcePasswordReset(ctx, adminUserID, targetUserID)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.ForcePasswordResetResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) VerifyEmail(ctx context.Context, req *authpb.VerifyEmailRequest) (*authpb.VerifyEmailResponse, error) {
+	err := s.authService.VerifyEmail(ctx, req.Token)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.VerifyEmailResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) ResendVerification(ctx context.Context, req *authpb.ResendVerificationRequest) (*authpb.ResendVerificationResponse, error) {
+	err := s.authService.ResendVerification(ctx, req.Email)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.ResendVerificationResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) ConfirmEmailChange(ctx context.Context, req *authpb.ConfirmEmailChangeRequest) (*authpb.ConfirmEmailChangeResponse, error) {
+	err := s.authService.ConfirmEmailChange(ctx, req.Token)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.ConfirmEmailChangeResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) RevertEmailChange(ctx context.Context, req *authpb.RevertEmailChangeRequest) (*authpb.RevertEmailChangeResponse, error) {
+	err := s.authService.RevertEmailChange(ctx, req.Token)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.RevertEmailChangeResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) RestoreAccount(ctx context.Context, req *authpb.RestoreAccountRequest) (*authpb.RestoreAccountResponse, error) {
+	err := s.authService.RestoreAccount(ctx, req.Email, req.Password)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.RestoreAccountResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) ListPendingDeletions(ctx context.Context, req *authpb.ListPendingDeletionsRequest) (*authpb.ListPendingDeletionsResponse, error) {
+	users, total, err := s.authService.ListPendingDeletions(ctx, req.Page, req.Limit)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	var pending []*authpb.PendingDeletion
+	for _, u := range users {
+		pending = append(pending, &authpb.PendingDeletion{
+			Id:         u.ID.Hex(),
+			Name:       u.Name,
+			Email:      u.Email,
+			DeletedAt:  timestamppb.New(u.DeletedAt),
+			PurgeAfter: timestamppb.New(u.PurgeAfter),
+		})
+	}
+
+	return &authpb.ListPendingDeletionsResponse{
+		Users: pending,
+		Total: total,
+	}, nil
+}
+
+func (s *AuthServiceHandler) ExpediteDeletion(ctx context.Context, req *authpb.ExpediteDeletionRequest) (*authpb.ExpediteDeletionResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	err = s.authService.ExpediteDeletion(ctx, adminUserID, targetUserID)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.ExpediteDeletionResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) SuspendUser(ctx context.Context, req *authpb.SuspendUserRequest) (*authpb.SuspendUserResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	var until time.Time
+	if req.Until != nil {
+		until = req.Until.AsTime()
+	}
+
+	err = s.authService.SuspendUser(ctx, adminUserID, targetUserID, req.Reason, until)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.SuspendUserResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) UnsuspendUser(ctx context.Context, req *authpb.UnsuspendUserRequest) (*authpb.UnsuspendUserResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	err = s.authService.UnsuspendUser(ctx, adminUserID, targetUserID)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.UnsuspendUserResponse{
+		Success: true,
+	}, nil
+}
+
+// userToProto returns the full admin view of a user. Password hashes never
+// leave the service.
+func userToProto(u *model.User) *authpb.User {
+	return &authpb.User{
+		Id:                  u.ID.Hex(),
+		Name:                u.Name,
+		Email:               u.Email,
+		Roles:               u.Roles,
+		EmailVerified:       u.EmailVerified,
+		PendingEmail:        u.PendingEmail,
+		MustChangePassword:  u.MustChangePassword,
+		Suspended:           u.Suspended,
+		SuspendedUntil:      optionalTimestamp(u.SuspendedUntil),
+		SuspensionReason:    u.SuspensionReason,
+		Deleted:             u.Deleted,
+		DeletedAt:           optionalTimestamp(u.DeletedAt),
+		PurgeAfter:          optionalTimestamp(u.PurgeAfter),
+		PasswordChangedAt:   optionalTimestamp(u.PasswordChangedAt),
+		CreatedAt:           optionalTimestamp(u.CreatedAt),
+		Attributes:          attributesToProto(u.Attributes),
+		Locked:              u.Locked,
+		LockedUntil:         activeLockedUntil(u),
+		FailedLoginAttempts: int32(u.FailedLoginAttempts),
+	}
+}
+
+func attributesToProto(attributes map[string]interface{}) map[string]*structpb.Value {
+	if len(attributes) == 0 {
+		return nil
+	}
+	values := make(map[string]*structpb.Value, len(attributes))
+	for name, value := range attributes {
+		v, err := structpb.NewValue(value)
+		if err != nil {
+			log.Printf("attribute %s has unsupported value %v: %v", name, value, err)
+			continue
+		}
+		values[name] = v
+	}
+	return values
+}
+
+// attributesFromProto keeps null values as nil, which removes the attribute.
+func attributesFromProto(values map[string]*structpb.Value) map[string]interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	attributes := make(map[string]interface{}, len(values))
+	for name, v := range values {
+		if v == nil {
+			attributes[name] = nil
+			continue
+		}
+		attributes[name] = v.AsInterface()
+	}
+	return attributes
+}
+
+func optionalTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func (s *AuthServiceHandler) AdminCreateUser(ctx context.Context, req *authpb.AdminCreateUserRequest) (*authpb.AdminCreateUserResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	user := &model.User{
+		Email:              req.Email,
+		Password:           req.Password,
+		Name:               req.Name,
+		Roles:              req.Roles,
+		EmailVerified:      req.EmailVerified,
+		MustChangePassword: req.MustChangePassword,
+		Attributes:         attributesFromProto(req.Attributes),
+	}
+
+	err := s.authService.AdminCreateUser(ctx, adminUserID, user)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.AdminCreateUserResponse{
+		User: userToProto(user),
+	}, nil
+}
+
+func (s *AuthServiceHandler) AdminUpdateUser(ctx context.Context, req *authpb.AdminUpdateUserRequest) (*authpb.AdminUpdateUserResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	update := &model.AdminUserUpdate{
+		Name:               req.Name,
+		Email:              req.Email,
+		EmailVerified:      req.EmailVerified,
+		MustChangePassword: req.MustChangePassword,
+		Attributes:         attributesFromProto(req.Attributes),
+	}
+	if req.Roles != nil {
+		roles := req.Roles.GetRoles()
+		update.Roles = &roles
+	}
+
+	user, err := s.authService.AdminUpdateUser(ctx, adminUserID, targetUserID, update)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.AdminUpdateUserResponse{
+		User: userToProto(user),
+	}, nil
+}
+
+func (s *AuthServiceHandler) AdminDeleteUser(ctx context.Context, req *authpb.AdminDeleteUserRequest) (*authpb.AdminDeleteUserResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	user, err := s.authService.AdminDeleteUser(ctx, adminUserID, targetUserID)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.AdminDeleteUserResponse{
+		User: userToProto(user),
+	}, nil
+}
+
+func (s *AuthServiceHandler) AdminRestoreUser(ctx context.Context, req *authpb.AdminRestoreUserRequest) (*authpb.AdminRestoreUserResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	user, err := s.authService.AdminRestoreUser(ctx, adminUserID, targetUserID)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.AdminRestoreUserResponse{
+		User: userToProto(user),
+	}, nil
+}
+
+func invitationToProto(i *model.Invitation) *authpb.Invitation {
+	pb := &authpb.Invitation{
+		Id:        i.ID.Hex(),
+		Email:     i.Email,
+		Role:      i.Role,
+		Status:    i.Status(time.Now()),
+		InvitedBy: i.InvitedBy.Hex(),
+		ExpiresAt: timestamppb.New(i.ExpiresAt),
+		CreatedAt: timestamppb.New(i.CreatedAt),
+	}
+	if i.AcceptedAt != nil {
+		pb.AcceptedAt = timestamppb.New(*i.AcceptedAt)
+		pb.UserId = i.UserID.Hex()
+	}
+	if i.RevokedAt != nil {
+		pb.RevokedAt = timestamppb.New(*i.RevokedAt)
+	}
+	return pb
+}
+
+func (s *AuthServiceHandler) InviteUser(ctx context.Context, req *authpb.InviteUserRequest) (*authpb.InviteUserResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	invitation, err := s.authService.InviteUser(ctx, adminUserID, req.Email, req.Role)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.InviteUserResponse{
+		Invitation: invitationToProto(invitation),
+	}, nil
+}
+
+func (s *AuthServiceHandler) AcceptInvitation(ctx context.Context, req *authpb.AcceptInvitationRequest) (*authpb.AcceptInvitationResponse, error) {
+	user, err := s.authService.AcceptInvitation(ctx, req.Token, req.Name, req.Password, attributesFromProto(req.Attributes))
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.AcceptInvitationResponse{
+		User: userToProto(user),
+	}, nil
+}
+
+func (s *AuthServiceHandler) ListInvitations(ctx context.Context, req *authpb.ListInvitationsRequest) (*authpb.ListInvitationsResponse, error) {
+	invitations, total, err := s.authService.ListInvitations(ctx, req.Status, req.Page, req.Limit)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	var invitationProtos []*authpb.Invitation
+	for _, i := range invitations {
+		invitationProtos = append(invitationProtos, invitationToProto(i))
+	}
+
+	return &authpb.ListInvitationsResponse{
+		Invitations: invitationProtos,
+		Total:       total,
+	}, nil
+}
+
+func (s *AuthServiceHandler) RevokeInvitation(ctx context.Context, req *authpb.RevokeInvitationRequest) (*authpb.RevokeInvitationResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	invitationID, err := primitive.ObjectIDFromHex(req.InvitationId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid invitation id")
+	}
+
+	err = s.authService.RevokeInvitation(ctx, adminUserID, invitationID)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.RevokeInvitationResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) ImportUsers(stream authpb.AuthService_ImportUsersServer) error {
+	ctx := stream.Context()
+
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	options := first.GetOptions()
+	if options == nil {
+		return status.Errorf(codes.InvalidArgument, "the first message must contain the import options")
+	}
+
+	opts := model.ImportOptions{
+		Mode:   model.ImportModeSkipExisting,
+		DryRun: options.DryRun,
+	}
+	if options.Mode == authpb.ImportMode_IMPORT_MODE_UPSERT {
+		opts.Mode = model.ImportModeUpsert
+	}
+
+	next := func() (*model.ImportRecord, error) {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		u := req.GetUser()
+		if u == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "expected a user record")
+		}
+
+		record := &model.ImportRecord{
+			Email:              u.Email,
+			Name:               u.Name,
+			Roles:              u.Roles,
+			Password:           u.Password,
+			PasswordHash:       u.PasswordHash,
+			EmailVerified:      u.EmailVerified,
+			MustChangePassword: u.MustChangePassword,
+			Attributes:         attributesFromProto(u.Attributes),
+		}
+		if u.CreatedAt != nil {
+			record.CreatedAt = u.CreatedAt.AsTime()
+		}
+		return record, nil
+	}
+
+	report := func(result *model.ImportResult) error {
+		res := &authpb.ImportUserResult{
+			Index:  int64(result.Index),
+			Email:  result.Email,
+			Status: result.Status,
+		}
+		if !result.UserID.IsZero() {
+			res.UserId = result.UserID.Hex()
+		}
+		if result.Err != nil {
+			st := status.Convert(grpcErrorFromService(result.Err))
+			res.ErrorCode = st.Code().String()
+			res.ErrorMessage = st.Message()
+		}
+		return stream.Send(res)
+	}
+
+	err = s.authService.ImportUsers(ctx, adminUserID, opts, next, report)
+	if _, isStatus := status.FromError(err); isStatus {
+		return err
+	}
+	return grpcErrorFromService(err)
+}
+
+func (s *AuthServiceHandler) ExportUsers(req *authpb.ExportUsersRequest, stream authpb.AuthService_ExportUsersServer) error {
+	ctx := stream.Context()
+
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	filter := &model.UserExportFilter{
+		Name:           req.Name,
+		Email:          req.Email,
+		Role:           req.Role,
+		IncludeDeleted: req.IncludeDeleted,
+	}
+	if req.CreatedAfter != nil {
+		filter.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		filter.CreatedBefore = req.CreatedBefore.AsTime()
+	}
+
+	err := s.authService.ExportUsers(ctx, adminUserID, filter, req.Checkpoint, func(u *model.User) error {
+		return stream.Send(&authpb.ExportUsersResponse{
+			User:       userToProto(u),
+			Checkpoint: service.EncodeExportCheckpoint(u.ID),
+		})
+	})
+	if _, isStatus := status.FromError(err); isStatus {
+		return err
+	}
+	return grpcErrorFromService(err)
+}
+
+func attributeDefinitionToProto(def *model.AttributeDefinition) *authpb.AttributeDefinition {
+	return &authpb.AttributeDefinition{
+		Name:         def.Name,
+		Type:         def.Type,
+		Description:  def.Description,
+		Required:     def.Required,
+		Pattern:      def.Pattern,
+		UserEditable: def.UserEditable,
+	}
+}
+
+func (s *AuthServiceHandler) DefineAttribute(ctx context.Context, req *authpb.DefineAttributeRequest) (*authpb.DefineAttributeResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	if req.Definition == nil {
+		return nil, status.Errorf(codes.InvalidArgument, "definition is required")
+	}
+
+	def := &model.AttributeDefinition{
+		Name:         req.Definition.Name,
+		Type:         req.Definition.Type,
+		Description:  req.Definition.Description,
+		Required:     req.Definition.Required,
+		Pattern:      req.Definition.Pattern,
+		UserEditable: req.Definition.UserEditable,
+	}
+
+	err := s.authService.DefineAttribute(ctx, adminUserID, def)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.DefineAttributeResponse{
+		Definition: attributeDefinitionToProto(def),
+	}, nil
+}
+
+func (s *AuthServiceHandler) ListAttributeDefinitions(ctx context.Context, req *authpb.ListAttributeDefinitionsRequest) (*authpb.ListAttributeDefinitionsResponse, error) {
+	defs, err := s.authService.ListAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	var definitions []*authpb.AttributeDefinition
+	for i := range defs {
+		definitions = append(definitions, attributeDefinitionToProto(&defs[i]))
+	}
+
+	return &authpb.ListAttributeDefinitionsResponse{
+		Definitions: definitions,
+	}, nil
+}
+
+func (s *AuthServiceHandler) DeleteAttributeDefinition(ctx context.Context, req *authpb.DeleteAttributeDefinitionRequest) (*authpb.DeleteAttributeDefinitionResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	err := s.authService.DeleteAttributeDefinition(ctx, adminUserID, req.Name)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.DeleteAttributeDefinitionResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) RequestAccountUnlock(ctx context.Context, req *authpb.RequestAccountUnlockRequest) (*authpb.RequestAccountUnlockResponse, error) {
+	err := s.authService.RequestAccountUnlock(ctx, req.Email)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.RequestAccountUnlockResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) ConfirmAccountUnlock(ctx context.Context, req *authpb.ConfirmAccountUnlockRequest) (*authpb.ConfirmAccountUnlockResponse, error) {
+	err := s.authService.ConfirmAccountUnlock(ctx, req.Token)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.ConfirmAccountUnlockResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) UnlockAccount(ctx context.Context, req *authpb.UnlockAccountRequest) (*authpb.UnlockAccountResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	err = s.authService.UnlockAccount(ctx, adminUserID, targetUserID)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.UnlockAccountResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) ListLockedAccounts(ctx context.Context, req *authpb.ListLockedAccountsRequest) (*authpb.ListLockedAccountsResponse, error) {
+	users, total, err := s.authService.ListLockedAccounts(ctx, req.Page, req.Limit)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	var accounts []*authpb.LockedAccount
+	for _, u := range users {
+		accounts = append(accounts, &authpb.LockedAccount{
+			Id:                  u.ID.Hex(),
+			Name:                u.Name,
+			Email:               u.Email,
+			FailedLoginAttempts: int32(u.FailedLoginAttempts),
+			Permanent:           u.Locked,
+			LockedUntil:         activeLockedUntil(u),
+			LockedAt:            optionalTimestamp(u.LockedAt),
+		})
+	}
+
+	return &authpb.ListLockedAccountsResponse{
+		Accounts: accounts,
+		Total:    total,
+	}, nil
+}
+
+// activeLockedUntil returns the end of the user's lockout window, or nil once
+// it has passed.
+func activeLockedUntil(u *model.User) *timestamppb.Timestamp {
+	if !u.LockedUntil.After(time.Now()) {
+		return nil
+	}
+	return timestamppb.New(u.LockedUntil)
+}
+
+func policyToProto(p *model.PolicyDocument) *authpb.Policy {
+	return &authpb.Policy{
+		Id:          p.ID.Hex(),
+		Type:        p.Type,
+		Version:     p.Version,
+		Title:       p.Title,
+		Url:         p.URL,
+		Content:     p.Content,
+		Mandatory:   p.Mandatory,
+		PublishedAt: timestamppb.New(p.PublishedAt),
+	}
+}
+
+func policiesToProto(policies []model.PolicyDocument) []*authpb.Policy {
+	var result []*authpb.Policy
+	for i := range policies {
+		result = append(result, policyToProto(&policies[i]))
+	}
+	return result
+}
+
+func policyVersionsFromProto(versions []*authpb.PolicyVersion) []model.PolicyVersion {
+	var result []model.PolicyVersion
+	for _, v := range versions {
+		result = append(result, model.PolicyVersion{Type: v.Type, Version: v.Version})
+	}
+	return result
+}
+
+func (s *AuthServiceHandler) PublishPolicy(ctx context.Context, req *authpb.PublishPolicyRequest) (*authpb.PublishPolicyResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	policy := &model.PolicyDocument{
+		Type:      req.Type,
+		Version:   req.Version,
+		Title:     req.Title,
+		URL:       req.Url,
+		Content:   req.Content,
+		Mandatory: req.Mandatory,
+	}
+	err := s.authService.PublishPolicy(ctx, adminUserID, policy)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.PublishPolicyResponse{
+		Policy: policyToProto(policy),
+	}, nil
+}
+
+func (s *AuthServiceHandler) GetCurrentPolicies(ctx context.Context, req *authpb.GetCurrentPoliciesRequest) (*authpb.GetCurrentPoliciesResponse, error) {
+	policies, err := s.authService.CurrentPolicies(ctx)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.GetCurrentPoliciesResponse{
+		Policies: policiesToProto(policies),
+	}, nil
+}
+
+func (s *AuthServiceHandler) ListPolicyVersions(ctx context.Context, req *authpb.ListPolicyVersionsRequest) (*authpb.ListPolicyVersionsResponse, error) {
+	policies, err := s.authService.ListPolicyVersions(ctx, req.Type)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.ListPolicyVersionsResponse{
+		Policies: policiesToProto(policies),
+	}, nil
+}
+
+func (s *AuthServiceHandler) AcceptPolicies(ctx context.Context, req *authpb.AcceptPoliciesRequest) (*authpb.AcceptPoliciesResponse, error) {
+	userID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	err := s.authService.AcceptPolicies(ctx, userID, policyVersionsFromProto(req.Policies))
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.AcceptPoliciesResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) GetPendingPolicies(ctx context.Context, req *authpb.GetPendingPoliciesRequest) (*authpb.GetPendingPoliciesResponse, error) {
+	userID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	policies, err := s.authService.PendingPolicies(ctx, userID)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.GetPendingPoliciesResponse{
+		Policies: policiesToProto(policies),
+	}, nil
+}
+
+func (s *AuthServiceHandler) ListPolicyAcceptances(ctx context.Context, req *authpb.ListPolicyAcceptancesRequest) (*authpb.ListPolicyAcceptancesResponse, error) {
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	acceptances, err := s.authService.ListPolicyAcceptances(ctx, targetUserID)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	var result []*authpb.PolicyAcceptance
+	for _, a := range acceptances {
+		result = append(result, &authpb.PolicyAcceptance{
+			Type:       a.Type,
+			Version:    a.Version,
+			Ip:         a.IP,
+			UserAgent:  a.UserAgent,
+			AcceptedAt: timestamppb.New(a.AcceptedAt),
+		})
+	}
+
+	return &authpb.ListPolicyAcceptancesResponse{
+		Acceptances: result,
+	}, nil
+}
+
+func roleToProto(r *model.Role) *authpb.Role {
+	return &authpb.Role{
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: r.Permissions,
+		BuiltIn:     r.BuiltIn,
+		CreatedAt:   optionalTimestamp(r.CreatedAt),
+		UpdatedAt:   optionalTimestamp(r.UpdatedAt),
+	}
+}
+
+func (s *AuthServiceHandler) CreateRole(ctx context.Context, req *authpb.CreateRoleRequest) (*authpb.CreateRoleResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	role := &model.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+	err := s.authService.CreateRole(ctx, adminUserID, role)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.CreateRoleResponse{
+		Role: roleToProto(role),
+	}, nil
+}
+
+func (s *AuthServiceHandler) UpdateRole(ctx context.Context, req *authpb.UpdateRoleRequest) (*authpb.UpdateRoleResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	role, err := s.authService.UpdateRole(ctx, adminUserID, req.Name, req.Description, req.Permissions)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.UpdateRoleResponse{
+		Role: roleToProto(role),
+	}, nil
+}
+
+func (s *AuthServiceHandler) DeleteRole(ctx context.Context, req *authpb.DeleteRoleRequest) (*authpb.DeleteRoleResponse, error) {
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	err := s.authService.DeleteRole(ctx, adminUserID, req.Name)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	return &authpb.DeleteRoleResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *AuthServiceHandler) ListRoles(ctx context.Context, req *authpb.ListRolesRequest) (*authpb.ListRolesResponse, error) {
+	roles, err := s.authService.ListRoles(ctx)
+	if err != nil {
+		return nil, grpcErrorFromService(err)
+	}
+
+	var result []*authpb.Role
+	for i := range roles {
+		result = append(result, roleToProto(&roles[i]))
+	}
+
+	return &authpb.ListRolesResponse{
+		Roles:       result,
+		Permissions: model.Permissions,
+	}, nil
+}
+
+// exportChunkSize keeps each streamed message well below the default 4 MiB
+// gRPC message limit.
+const exportChunkSize = 64 * 1024
+
+func (s *AuthServiceHandler) ExportMyData(req *authpb.ExportMyDataRequest, stream authpb.AuthService_ExportMyDataServer) error {
+	ctx := stream.Context()
+
+	userID, ok := authz.UserID(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	export, err := s.authService.ExportMyData(ctx, userID)
+	if err != nil {
+		return grpcErrorFromService(err)
+	}
+	return sendDataExport(stream, export)
+}
+
+func (s *AuthServiceHandler) AdminExportUserData(req *authpb.AdminExportUserDataRequest, stream authpb.AuthService_AdminExportUserDataServer) error {
+	ctx := stream.Context()
+
+	adminUserID, ok := authz.UserID(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "missing user id in context")
+	}
+
+	targetUserID, err := primitive.ObjectIDFromHex(req.UserId)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid target user id")
+	}
+
+	export, err := s.authService.AdminExportUserData(ctx, adminUserID, targetUserID)
+	if err != nil {
+		return grpcErrorFromService(err)
+	}
+	return sendDataExport(stream, export)
+}
+
+// dataExportStream is satisfied by the server streams of both export RPCs.
+type dataExportStream interface {
+	Send(*authpb.DataExportChunk) error
+}
+
+func sendDataExport(stream dataExportStream, export *model.UserDataExport) error {
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		log.Printf("marshalling data export failed: %v", err)
+		return status.Errorf(codes.Internal, "internal error")
+	}
+
+	for start := 0; start < len(data); start += exportChunkSize {
+		end := min(start+exportChunkSize, len(data))
+		if err := stream.Send(&authpb.DataExportChunk{Data: data[start:end]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
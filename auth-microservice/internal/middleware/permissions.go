@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RolePermissions grants the permissions of the roles stored in MongoDB. It
+// implements authz.PermissionChecker.
+type RolePermissions struct{}
+
+func (RolePermissions) HasPermissions(ctx context.Context, userID primitive.ObjectID, permissions []string) (bool, error) {
+	user, err := repository.GetUserByID(userID)
+	if err == mongo.ErrNoDocuments {
+		return false, status.Errorf(codes.Unauthenticated, "user no longer exists")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var roles []model.Role
+	if len(user.Roles) > 0 {
+		roles, err = repository.GetRolesByName(ctx, user.Roles)
+		if err != nil {
+			return false, err
+		}
+	}
+	for _, p := range permissions {
+		if !model.RolesGrant(roles, p) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// PolicyAcceptance checks that users accepted the latest mandatory version of
+// every policy. It implements authz.AcceptanceChecker.
+type PolicyAcceptance struct{}
+
+func (PolicyAcceptance) HasAcceptedPolicies(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	required, err := repository.LatestPolicies(ctx, true)
+	if err != nil {
+		return false, err
+	}
+	return repository.HasAcceptedPolicies(ctx, userID, required)
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"auth-microservice/internal/utils"
+	"auth-microservice/pkg/authz"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sessions checks access tokens issued by Login against the token blacklist
+// and the sessions stored in MongoDB. It implements authz.SessionChecker.
+type Sessions struct{}
+
+func (Sessions) CheckSession(ctx context.Context, token string) (*authz.Caller, error) {
+	claims, err := utils.ParseAccessToken(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+
+	// --- เพิ่มการตรวจสอบ token ใน blacklist ---
+	isBlacklisted, err := repository.IsTokenBlacklisted(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error checking token blacklist: %v", err)
+	}
+	if isBlacklisted {
+		return nil, status.Errorf(codes.Unauthenticated, "token has been revoked")
+	}
+	// ---------------------------------------------
+
+	userID, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: malformed user id")
+	}
+	sessionID, err := primitive.ObjectIDFromHex(claims.SessionID)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: malformed session id")
+	}
+	active, err := repository.IsSessionActive(ctx, sessionID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error checking session: %v", err)
+	}
+	if !active {
+		reason, err := repository.SessionRevokeReason(ctx, sessionID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "error checking session: %v", err)
+		}
+		if reason == model.SessionRevokedSuspended {
+			// Sessions stay revoked after the suspension ends, but are
+			// only reported as suspended while it lasts.
+			suspended, err := stillSuspended(userID)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "error checking suspension: %v", err)
+			}
+			if suspended {
+				return nil, accountSuspendedError()
+			}
+		}
+		return nil, status.Errorf(codes.Unauthenticated, "session has been revoked")
+	}
+
+	return &authz.Caller{
+		UserID:    userID,
+		SessionID: sessionID,
+		Scope:     claims.Scope,
+	}, nil
+}
+
+// stillSuspended reports whether the user is suspended right now. Users that
+// no longer exist are not.
+func stillSuspended(userID primitive.ObjectID) (bool, error) {
+	user, err := repository.GetUserByID(userID)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.IsSuspended(time.Now()), nil
+}
+
+// accountSuspendedError matches the error Login returns for suspended
+// accounts so clients can handle both the same way.
+func accountSuspendedError() error {
+	st := status.New(codes.PermissionDenied, "account is suspended")
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: "ACCOUNT_SUSPENDED",
+		Domain: "auth",
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AttributeTypeString = "string"
+	AttributeTypeNumber = "number"
+	AttributeTypeBool   = "bool"
+)
+
+// AttributeDefinition describes one custom attribute that may be stored in
+// User.Attributes. Pattern is a regular expression that string values must
+// match. Attributes that are not UserEditable can only be set by admins.
+type AttributeDefinition struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	Name         string             `bson:"name"`
+	Type         string             `bson:"type"`
+	Description  string             `bson:"description,omitempty"`
+	Required     bool               `bson:"required"`
+	Pattern      string             `bson:"pattern,omitempty"`
+	UserEditable bool               `bson:"user_editable"`
+	CreatedAt    time.Time          `bson:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at"`
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AuditActionForcePasswordReset = "password.force_reset"
+	AuditActionExpediteDeletion   = "account.expedite_deletion"
+	AuditActionRoleChange         = "role.change"
+	AuditActionExportUserData     = "account.export"
+	AuditActionSuspendUser        = "account.suspend"
+	AuditActionUnsuspendUser      = "account.unsuspend"
+	AuditActionCreateUser         = "account.create"
+	AuditActionUpdateUser         = "account.update"
+	AuditActionDeleteUser         = "account.delete"
+	AuditActionRestoreUser        = "account.restore"
+	AuditActionInviteUser         = "invitation.create"
+	AuditActionRevokeInvitation   = "invitation.revoke"
+	AuditActionImportUsers        = "account.import"
+	AuditActionExportUsers        = "account.bulk_export"
+	AuditActionDefineAttribute    = "attribute.define"
+	AuditActionDeleteAttribute    = "attribute.delete"
+	AuditActionUnlockAccount      = "account.unlock"
+	AuditActionPublishPolicy      = "policy.publish"
+	AuditActionCreateRole         = "role.create"
+	AuditActionUpdateRole         = "role.update"
+	AuditActionDeleteRole         = "role.delete"
+)
+
+// AuditEntry records an administrative action. TargetUserID is unset for
+// actions that do not concern an existing account, such as invitations.
+type AuditEntry struct {
+	ID           primitive.ObjectID     `bson:"_id,omitempty"`
+	ActorID      primitive.ObjectID     `bson:"actor_id"`
+	Action       string                 `bson:"action"`
+	TargetUserID primitive.ObjectID     `bson:"target_user_id,omitempty"`
+	Details      map[string]interface{} `bson:"details,omitempty"`
+	CreatedAt    time.Time              `bson:"created_at"`
+}
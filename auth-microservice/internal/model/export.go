@@ -0,0 +1,75 @@
+package model
+
+import "time"
+
+// UserDataExport is the machine-readable document returned by the data export
+// RPCs. It deliberately leaves out password hashes and password history.
+type UserDataExport struct {
+	ExportedAt        time.Time                `json:"exported_at"`
+	Profile           ExportProfile            `json:"profile"`
+	RoleHistory       []ExportRoleChange       `json:"role_history"`
+	Sessions          []ExportSession          `json:"sessions"`
+	LoginHistory      []ExportLoginEvent       `json:"login_history"`
+	PolicyAcceptances []ExportPolicyAcceptance `json:"policy_acceptances"`
+	AuditEntries      []ExportAuditEntry       `json:"audit_entries"`
+}
+
+type ExportProfile struct {
+	ID                 string                 `json:"id"`
+	Name               string                 `json:"name"`
+	Email              string                 `json:"email"`
+	EmailVerified      bool                   `json:"email_verified"`
+	EmailVerifiedAt    *time.Time             `json:"email_verified_at,omitempty"`
+	PendingEmail       string                 `json:"pending_email,omitempty"`
+	Roles              []string               `json:"roles"`
+	PasswordChangedAt  *time.Time             `json:"password_changed_at,omitempty"`
+	MustChangePassword bool                   `json:"must_change_password"`
+	Suspended          bool                   `json:"suspended"`
+	SuspendedUntil     *time.Time             `json:"suspended_until,omitempty"`
+	SuspensionReason   string                 `json:"suspension_reason,omitempty"`
+	Locked             bool                   `json:"locked"`
+	LockedUntil        *time.Time             `json:"locked_until,omitempty"`
+	Attributes         map[string]interface{} `json:"attributes,omitempty"`
+	Deleted            bool                   `json:"deleted"`
+	PurgeAfter         *time.Time             `json:"purge_after,omitempty"`
+	CreatedAt          time.Time              `json:"created_at"`
+}
+
+type ExportRoleChange struct {
+	ChangedBy string    `json:"changed_by"`
+	From      []string  `json:"from"`
+	To        []string  `json:"to"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type ExportSession struct {
+	ID        string     `json:"id"`
+	Scope     string     `json:"scope,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type ExportLoginEvent struct {
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportPolicyAcceptance struct {
+	Type       string    `json:"type"`
+	Version    string    `json:"version"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+type ExportAuditEntry struct {
+	ActorID      string                 `json:"actor_id"`
+	Action       string                 `json:"action"`
+	TargetUserID string                 `json:"target_user_id"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
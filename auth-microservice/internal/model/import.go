@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How ImportUsers treats records whose email already belongs to an active
+// account.
+const (
+	ImportModeSkipExisting = "skip_existing"
+	ImportModeUpsert       = "upsert"
+)
+
+const (
+	ImportStatusCreated = "created"
+	ImportStatusUpdated = "updated"
+	ImportStatusSkipped = "skipped"
+	ImportStatusFailed  = "failed"
+)
+
+type ImportOptions struct {
+	Mode string
+	// DryRun validates every record and reports what would happen without
+	// writing anything.
+	DryRun bool
+}
+
+// ImportRecord is one user to import. Exactly one of Password (plain text,
+// checked against the password policy) and PasswordHash (an existing argon2id
+// or bcrypt hash) must be set.
+type ImportRecord struct {
+	Email              string
+	Name               string
+	Roles              []string
+	Password           string
+	PasswordHash       string
+	EmailVerified      bool
+	MustChangePassword bool
+	CreatedAt          time.Time
+	Attributes         map[string]interface{}
+}
+
+type ImportResult struct {
+	Index  int
+	Email  string
+	Status string
+	UserID primitive.ObjectID
+	Err    error
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusRevoked  = "revoked"
+	InvitationStatusExpired  = "expired"
+)
+
+// Invitation lets someone create an account with a role chosen by an admin.
+// Like action tokens, only the SHA-256 hash of the invitation token is stored.
+type Invitation struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Email      string             `bson:"email"`
+	Role       string             `bson:"role"`
+	TokenHash  string             `bson:"token_hash"`
+	InvitedBy  primitive.ObjectID `bson:"invited_by"`
+	ExpiresAt  time.Time          `bson:"expires_at"`
+	AcceptedAt *time.Time         `bson:"accepted_at,omitempty"`
+	UserID     primitive.ObjectID `bson:"user_id,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at"`
+}
+
+// Status derives the state of the invitation at now.
+func (i *Invitation) Status(now time.Time) string {
+	switch {
+	case i.AcceptedAt != nil:
+		return InvitationStatusAccepted
+	case i.RevokedAt != nil:
+		return InvitationStatusRevoked
+	case !now.Before(i.ExpiresAt):
+		return InvitationStatusExpired
+	default:
+		return InvitationStatusPending
+	}
+}
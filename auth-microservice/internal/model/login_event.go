@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginEvent is one login attempt against a known account.
+type LoginEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Success   bool               `bson:"success"`
+	Reason    string             `bson:"reason,omitempty"`
+	IP        string             `bson:"ip,omitempty"`
+	UserAgent string             `bson:"user_agent,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PolicyTypeTermsOfService = "terms_of_service"
+	PolicyTypePrivacyPolicy  = "privacy_policy"
+)
+
+// PolicyDocument is one published version of a legal document. The most
+// recently published version of each type is the current one. When a
+// Mandatory version is published, users must accept it (or a later version)
+// before they can use the service again.
+type PolicyDocument struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Type        string             `bson:"type"`
+	Version     string             `bson:"version"`
+	Title       string             `bson:"title"`
+	URL         string             `bson:"url,omitempty"`
+	Content     string             `bson:"content,omitempty"`
+	Mandatory   bool               `bson:"mandatory"`
+	PublishedBy primitive.ObjectID `bson:"published_by"`
+	PublishedAt time.Time          `bson:"published_at"`
+}
+
+// PolicyVersion identifies a policy version a user agreed to.
+type PolicyVersion struct {
+	Type    string
+	Version string
+}
+
+// AcceptedPolicy is the latest accepted version of one policy type, kept on
+// the user so that the interceptor can check acceptance with one query.
+type AcceptedPolicy struct {
+	Version     string    `bson:"version"`
+	PublishedAt time.Time `bson:"published_at"`
+	AcceptedAt  time.Time `bson:"accepted_at"`
+}
+
+// PolicyAcceptance records that a user accepted a policy version. These
+// records are the proof of acceptance and are never updated.
+type PolicyAcceptance struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	UserID     primitive.ObjectID `bson:"user_id"`
+	PolicyID   primitive.ObjectID `bson:"policy_id"`
+	Type       string             `bson:"type"`
+	Version    string             `bson:"version"`
+	IP         string             `bson:"ip,omitempty"`
+	UserAgent  string             `bson:"user_agent,omitempty"`
+	AcceptedAt time.Time          `bson:"accepted_at"`
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Built-in roles. They always exist and cannot be deleted. RoleAdmin is
+// granted every permission and RoleUser is the default for new accounts.
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+const (
+	PermissionUsersRead         = "users.read"
+	PermissionUsersCreate       = "users.create"
+	PermissionUsersUpdate       = "users.update"
+	PermissionUsersDelete       = "users.delete"
+	PermissionUsersSuspend      = "users.suspend"
+	PermissionUsersUnlock       = "users.unlock"
+	PermissionUsersExport       = "users.export"
+	PermissionUsersImport       = "users.import"
+	PermissionRolesRead         = "roles.read"
+	PermissionRolesAssign       = "roles.assign"
+	PermissionRolesManage       = "roles.manage"
+	PermissionInvitationsManage = "invitations.manage"
+	PermissionAttributesManage  = "attributes.manage"
+	PermissionPoliciesManage    = "policies.manage"
+)
+
+// Permissions lists every permission a role can grant.
+var Permissions = []string{
+	PermissionUsersRead,
+	PermissionUsersCreate,
+	PermissionUsersUpdate,
+	PermissionUsersDelete,
+	PermissionUsersSuspend,
+	PermissionUsersUnlock,
+	PermissionUsersExport,
+	PermissionUsersImport,
+	PermissionRolesRead,
+	PermissionRolesAssign,
+	PermissionRolesManage,
+	PermissionInvitationsManage,
+	PermissionAttributesManage,
+	PermissionPoliciesManage,
+}
+
+func IsPermission(name string) bool {
+	for _, p := range Permissions {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is a named set of permissions that can be assigned to users.
+type Role struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Name        string             `bson:"name"`
+	Description string             `bson:"description,omitempty"`
+	Permissions []string           `bson:"permissions"`
+	BuiltIn     bool               `bson:"built_in"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at"`
+}
+
+func (r *Role) HasPermission(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RolesCover reports whether granted holds every permission that any of
+// required grants.
+func RolesCover(granted, required []Role) bool {
+	for i := range required {
+		for _, p := range required[i].Permissions {
+			if !RolesGrant(granted, p) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// RolesGrant reports whether any of roles grants permission.
+func RolesGrant(roles []Role, permission string) bool {
+	for i := range roles {
+		if roles[i].HasPermission(permission) {
+			return true
+		}
+	}
+	return false
+}
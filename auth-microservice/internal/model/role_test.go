@@ -0,0 +1,35 @@
+package model
+
+import "testing"
+
+func TestRolesCover(t *testing.T) {
+	admin := Role{Name: RoleAdmin, Permissions: Permissions}
+	user := Role{Name: RoleUser}
+	support := Role{Name: "support", Permissions: []string{PermissionUsersRead, PermissionUsersUpdate}}
+	manager := Role{Name: "manager", Permissions: []string{PermissionRolesManage}}
+	auditor := Role{Name: "auditor", Permissions: []string{PermissionUsersRead}}
+
+	tests := []struct {
+		name     string
+		granted  []Role
+		required []Role
+		want     bool
+	}{
+		{"anyone covers a plain user", []Role{support}, []Role{user}, true},
+		{"anyone covers no roles", nil, nil, true},
+		{"same role", []Role{support}, []Role{support}, true},
+		{"subset of permissions", []Role{support}, []Role{auditor}, true},
+		{"admin covers everyone", []Role{admin}, []Role{support, manager}, true},
+		{"users.update does not cover admin", []Role{support}, []Role{admin}, false},
+		{"users.update does not cover roles.manage", []Role{support}, []Role{user, manager}, false},
+		{"permissions spread over several roles", []Role{support, manager}, []Role{auditor, manager}, true},
+		{"plain user covers nothing privileged", []Role{user}, []Role{auditor}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RolesCover(tt.granted, tt.required); got != tt.want {
+				t.Errorf("RolesCover = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionRevokedSuspended marks sessions revoked because the account was
+// suspended, so requests using them can be told apart from signed-out ones.
+const SessionRevokedSuspended = "suspended"
+
+// Session tracks a single issued access token so it can be revoked
+// independently of the user's other logins.
+type Session struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	UserID        primitive.ObjectID `bson:"user_id"`
+	Scope         string             `bson:"scope,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	ExpiresAt     time.Time          `bson:"expires_at"`
+	RevokedAt     *time.Time         `bson:"revoked_at,omitempty"`
+	RevokedReason string             `bson:"revoked_reason,omitempty"`
+}
@@ -1,8 +1,34 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type BlacklistedToken struct {
 	Token     string    `bson:"token"`
 	ExpiredAt time.Time `bson:"expired_at"`
 }
+
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeEmailChange       = "email_change"
+	TokenPurposeEmailChangeRevert = "email_change_revert"
+	TokenPurposeAccountUnlock     = "account_unlock"
+)
+
+// ActionToken is a single-use token delivered out of band (e.g. by email).
+// Only the SHA-256 hash of the token is stored. Email is set for tokens
+// that apply to a specific address, such as email change confirmations.
+type ActionToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"token_hash"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Purpose   string             `bson:"purpose"`
+	Email     string             `bson:"email,omitempty"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
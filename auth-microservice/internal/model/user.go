@@ -7,21 +7,92 @@ import (
 )
 
 type User struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty"`
-	Name       string             `bson:"name"`
-	Email      string             `bson:"email"`
-	Role       string             `bson:"role"`
-	Password   string             `bson:"password"`
-	Deleted    bool               `bson:"deleted"`
-	CreatedAt  time.Time          `bson:"created_at"`
-	updated_at time.Time          `bson:"updated_at"`
+	ID                    primitive.ObjectID        `bson:"_id,omitempty"`
+	Name                  string                    `bson:"name"`
+	Email                 string                    `bson:"email"`
+	EmailVerified         bool                      `bson:"email_verified"`
+	EmailVerifiedAt       time.Time                 `bson:"email_verified_at,omitempty"`
+	PendingEmail          string                    `bson:"pending_email,omitempty"`
+	Roles                 []string                  `bson:"roles"`
+	Password              string                    `bson:"password"`
+	PasswordHistory       []string                  `bson:"password_history,omitempty"`
+	PasswordChangedAt     time.Time                 `bson:"password_changed_at,omitempty"`
+	MustChangePassword    bool                      `bson:"must_change_password"`
+	PasswordResetForcedBy primitive.ObjectID        `bson:"password_reset_forced_by,omitempty"`
+	PasswordResetForcedAt time.Time                 `bson:"password_reset_forced_at,omitempty"`
+	Suspended             bool                      `bson:"suspended,omitempty"`
+	SuspendedAt           time.Time                 `bson:"suspended_at,omitempty"`
+	SuspendedUntil        time.Time                 `bson:"suspended_until,omitempty"`
+	SuspendedBy           primitive.ObjectID        `bson:"suspended_by,omitempty"`
+	SuspensionReason      string                    `bson:"suspension_reason,omitempty"`
+	FailedLoginAttempts   int                       `bson:"failed_login_attempts,omitempty"`
+	LastFailedLoginAt     time.Time                 `bson:"last_failed_login_at,omitempty"`
+	Locked                bool                      `bson:"locked,omitempty"`
+	LockedAt              time.Time                 `bson:"locked_at,omitempty"`
+	LockedUntil           time.Time                 `bson:"locked_until,omitempty"`
+	Attributes            map[string]interface{}    `bson:"attributes,omitempty"`
+	AcceptedPolicies      map[string]AcceptedPolicy `bson:"accepted_policies,omitempty"`
+	Deleted               bool                      `bson:"deleted"`
+	DeletedAt             time.Time                 `bson:"deleted_at,omitempty"`
+	PurgeAfter            time.Time                 `bson:"purge_after,omitempty"`
+	AnonymizedAt          time.Time                 `bson:"anonymized_at,omitempty"`
+	CreatedAt             time.Time                 `bson:"created_at"`
+	updated_at            time.Time                 `bson:"updated_at"`
 }
 
+// IsSuspended reports whether the user is suspended at now. Suspensions with
+// an end time lapse on their own once it has passed.
+func (u *User) IsSuspended(now time.Time) bool {
+	if !u.Suspended {
+		return false
+	}
+	return u.SuspendedUntil.IsZero() || now.Before(u.SuspendedUntil)
+}
+
+func (u *User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// UserFilter selects users for ListUsers. Attribute values may be given as
+// strings; ListUsers converts them to the attribute's type before matching.
 type UserFilter struct {
+	Name       string
+	Email      string
+	Attributes map[string]interface{}
+	Page       int64
+	Limit      int64
+}
+
+// UserExportFilter selects the users streamed by ExportUsers. Only users with
+// an id greater than After are returned, which lets an export resume.
+type UserExportFilter struct {
 	Name  string
 	Email string
-	Page  int64
-	Limit int64
+	// Role selects users holding this role among others.
+	Role           string
+	IncludeDeleted bool
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	After          primitive.ObjectID
+}
+
+// AdminUserUpdate holds the fields an admin wants to change. Nil fields are
+// left as they are.
+type AdminUserUpdate struct {
+	Name  *string
+	Email *string
+	// Roles replaces every role of the user when it is not nil.
+	Roles              *[]string
+	EmailVerified      *bool
+	MustChangePassword *bool
+	// Attributes are merged into the user's attributes; a nil value removes
+	// the attribute.
+	Attributes map[string]interface{}
 }
 
 type UpdateProfileInput struct {
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserIsSuspended(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		user User
+		want bool
+	}{
+		{"not suspended", User{}, false},
+		{"indefinitely", User{Suspended: true}, true},
+		{"until later", User{Suspended: true, SuspendedUntil: now.Add(time.Hour)}, true},
+		{"until now", User{Suspended: true, SuspendedUntil: now}, false},
+		{"lapsed", User{Suspended: true, SuspendedUntil: now.Add(-time.Hour)}, false},
+		{"unsuspended", User{Suspended: false, SuspendedUntil: now.Add(time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.IsSuspended(now); got != tt.want {
+				t.Errorf("IsSuspended = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"auth-microservice/internal/config"
+)
+
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUpper        = "require_upper"
+	RuleLower        = "require_lower"
+	RuleDigit        = "require_digit"
+	RuleSymbol       = "require_symbol"
+	RuleStrength     = "min_strength"
+	RulePersonalInfo = "no_personal_info"
+	RuleDictionary   = "dictionary"
+)
+
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// MaxBytes limits the length of the UTF-8 encoded password for hashers
+	// that cannot take longer ones, such as bcrypt. 0 means no limit.
+	MaxBytes           int
+	RequireUpper       bool
+	RequireLower       bool
+	RequireDigit       bool
+	RequireSymbol      bool
+	MinStrength        int
+	RejectPersonalInfo bool
+	Dictionary         []string
+}
+
+func LoadFromEnv() (*Policy, error) {
+	p := &Policy{
+		MinLength:          config.Int("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:          config.Int("PASSWORD_MAX_LENGTH", 128),
+		RequireUpper:       config.Bool("PASSWORD_REQUIRE_UPPER", true),
+		RequireLower:       config.Bool("PASSWORD_REQUIRE_LOWER", true),
+		RequireDigit:       config.Bool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol:      config.Bool("PASSWORD_REQUIRE_SYMBOL", false),
+		MinStrength:        config.Int("PASSWORD_MIN_STRENGTH", 2),
+		RejectPersonalInfo: config.Bool("PASSWORD_REJECT_PERSONAL_INFO", true),
+	}
+
+	if path := config.String("PASSWORD_DICTIONARY_FILE", ""); path != "" {
+		words, err := loadDictionary(path)
+		if err != nil {
+			return nil, err
+		}
+		p.Dictionary = words
+	}
+
+	if p.MinLength < 1 || (p.MaxLength > 0 && p.MaxLength < p.MinLength) {
+		return nil, fmt.Errorf("invalid password length bounds %d..%d", p.MinLength, p.MaxLength)
+	}
+	if p.MinStrength < 0 || p.MinStrength > 4 {
+		return nil, fmt.Errorf("PASSWORD_MIN_STRENGTH must be between 0 and 4")
+	}
+	return p, nil
+}
+
+func loadDictionary(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}
+
+// Validate returns every rule the password violates. personalInfo holds
+// values the password must not contain, such as the user's name and email.
+func (p *Policy) Validate(password string, personalInfo ...string) []Violation {
+	var violations []Violation
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, Violation{RuleMinLength, fmt.Sprintf("must be at least %d characters long", p.MinLength)})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, Violation{RuleMaxLength, fmt.Sprintf("must be at most %d characters long", p.MaxLength)})
+	} else if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, Violation{RuleMaxLength, fmt.Sprintf("must be at most %d bytes long", p.MaxBytes)})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, Violation{RuleUpper, "must contain an uppercase letter"})
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, Violation{RuleLower, "must contain a lowercase letter"})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, Violation{RuleDigit, "must contain a digit"})
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, Violation{RuleSymbol, "must contain a symbol"})
+	}
+
+	lower := strings.ToLower(password)
+	substituted := unleet(lower)
+	contains := func(word string) bool {
+		return strings.Contains(lower, word) || strings.Contains(substituted, word)
+	}
+
+	personal := personalTokens(personalInfo)
+	if p.RejectPersonalInfo {
+		for _, token := range personal {
+			if contains(token) {
+				violations = append(violations, Violation{RulePersonalInfo, "must not contain your name or email address"})
+				break
+			}
+		}
+	}
+
+	for _, word := range p.Dictionary {
+		if lower == word || substituted == word || (len(word) >= 4 && contains(word)) {
+			violations = append(violations, Violation{RuleDictionary, "must not contain a blocked word"})
+			break
+		}
+	}
+
+	if p.MinStrength > 0 {
+		extra := append(append([]string{}, p.Dictionary...), personal...)
+		if score := Strength(password, extra...); score < p.MinStrength {
+			violations = append(violations, Violation{RuleStrength, fmt.Sprintf("is too easy to guess (strength %d of 4, need %d)", score, p.MinStrength)})
+		}
+	}
+
+	return violations
+}
+
+// personalTokens splits names and email addresses into the lowercase pieces
+// that are long enough to be meaningful inside a password.
+func personalTokens(values []string) []string {
+	var tokens []string
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			value = local
+		}
+		for _, part := range strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(part) >= 3 {
+				tokens = append(tokens, part)
+			}
+		}
+	}
+	return tokens
+}
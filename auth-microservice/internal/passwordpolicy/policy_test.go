@@ -0,0 +1,105 @@
+package passwordpolicy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStrength(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		extraWords []string
+		want       int
+	}{
+		{"empty", "", nil, 0},
+		{"common word", "password", nil, 0},
+		{"leet common word", "P@ssw0rd", nil, 0},
+		{"keyboard run", "qwerty123", nil, 0},
+		{"sequence", "abcdefgh", nil, 0},
+		{"repeat", "aaaaaaaa", nil, 0},
+		{"word and year", "Summer2024", nil, 1},
+		{"name and year", "john1987", nil, 3},
+		{"name as extra word", "john1987", []string{"john"}, 0},
+		{"uncommon words", "Grid-Whiz7pine", nil, 4},
+		{"passphrase", "correct horse battery staple", nil, 4},
+		{"random", "tR8#vQ2!mZ9^kL4@", nil, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Strength(tt.password, tt.extraWords...); got != tt.want {
+				t.Errorf("Strength(%q) = %d, want %d", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	policy := &Policy{
+		MinLength:          8,
+		MaxLength:          20,
+		RequireUpper:       true,
+		RequireLower:       true,
+		RequireDigit:       true,
+		RequireSymbol:      true,
+		MinStrength:        2,
+		RejectPersonalInfo: true,
+		Dictionary:         []string{"acme"},
+	}
+
+	tests := []struct {
+		name         string
+		password     string
+		personalInfo []string
+		want         []string
+	}{
+		{"valid", "Grid-Whiz7pine", nil, nil},
+		{"too short", "Gw7-p", nil, []string{RuleMinLength}},
+		{"too long", "Grid-Whiz7pine-Grid-Whiz7pine", nil, []string{RuleMaxLength}},
+		{"missing classes", "gridwhizpine", nil, []string{RuleUpper, RuleDigit, RuleSymbol}},
+		{"personal info", "Grid-John7pine", []string{"John Smith", "john@example.com"}, []string{RulePersonalInfo}},
+		{"leet personal info", "Grid-J0hn7pine", []string{"John Smith"}, []string{RulePersonalInfo}},
+		{"dictionary word", "Grid-Acme7pine", nil, []string{RuleDictionary}},
+		{"guessable", "P@ssw0rd", nil, []string{RuleStrength}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range policy.Validate(tt.password, tt.personalInfo...) {
+				got = append(got, v.Rule)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%q) violated %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMaxBytes(t *testing.T) {
+	policy := &Policy{MinLength: 8, MaxLength: 128, MaxBytes: 72}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"at the limit", strings.Repeat("a", 72), nil},
+		{"over the limit", strings.Repeat("a", 73), []string{RuleMaxLength}},
+		{"multibyte over the limit", strings.Repeat("ü", 37), []string{RuleMaxLength}},
+		{"over the character limit", strings.Repeat("a", 129), []string{RuleMaxLength}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range policy.Validate(tt.password) {
+				if v.Rule == RuleMaxLength || v.Rule == RuleMinLength {
+					got = append(got, v.Rule)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate(%d bytes) violated %v, want %v", len(tt.password), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package passwordpolicy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// commonWords is a short frequency-ordered list of words that dominate
+// real-world password dumps. Position in the list approximates guess rank.
+var commonWords = []string{
+	"password", "123456", "qwerty", "admin", "welcome", "letmein", "monkey", "dragon", "login", "abc123",
+	"iloveyou", "master", "sunshine", "princess", "football", "baseball", "shadow", "superman", "batman", "trustno1",
+	"hello", "freedom", "whatever", "michael", "charlie", "jordan", "jennifer", "hunter", "ranger", "buster",
+	"soccer", "hockey", "killer", "george", "andrew", "thomas", "summer", "winter", "spring", "autumn",
+	"secret", "love", "test", "user", "pass", "passw0rd", "changeme", "default", "guest", "root",
+	"access", "flower", "cheese", "computer", "internet", "starwars", "pokemon", "naruto", "ninja", "mustang",
+	"matrix", "maggie", "ginger", "pepper", "cookie", "chocolate", "purple", "orange", "yellow", "silver",
+	"golden", "diamond", "angel", "lovely", "family", "friend", "forever", "happy", "lucky", "money",
+	"secure", "system", "server", "office", "company", "account", "october", "november", "december", "january",
+	"february", "march", "april", "june", "july", "august", "september", "monday", "friday", "sunday",
+	"gridwhiz", "qwertyuiop", "asdfgh", "zxcvbn", "abcdef", "abcd", "temp", "demo", "sample", "hello123",
+}
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+var leetTable = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i',
+	'0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+func unleet(s string) string {
+	return strings.Map(func(r rune) rune {
+		if sub, ok := leetTable[r]; ok {
+			return sub
+		}
+		return r
+	}, s)
+}
+
+// Strength scores a password from 0 (trivially guessable) to 4 (very hard to
+// guess) in the spirit of zxcvbn: the password is split into the cheapest
+// sequence of patterns (dictionary words, repeats, sequences, keyboard runs,
+// years and brute-forced characters) and the score is derived from the
+// estimated number of guesses. extraWords are treated as top-ranked
+// dictionary entries.
+func Strength(password string, extraWords ...string) int {
+	guesses := estimateGuesses(password, extraWords)
+	switch {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func estimateGuesses(password string, extraWords []string) float64 {
+	original := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	leet := []rune(unleet(strings.ToLower(password)))
+
+	guesses := 1.0
+	segments := 0
+	bruteLen, bruteClasses := 0, map[int]bool{}
+
+	flushBrute := func() {
+		if bruteLen == 0 {
+			return
+		}
+		cardinality := 0
+		for class := range bruteClasses {
+			cardinality += classCardinality[class]
+		}
+		for i := 0; i < bruteLen; i++ {
+			guesses *= float64(cardinality)
+		}
+		segments++
+		bruteLen, bruteClasses = 0, map[int]bool{}
+	}
+
+	for i := 0; i < len(original); {
+		n, g := 0, 0.0
+		for _, match := range []func() (int, float64){
+			func() (int, float64) { return dictionaryMatch(original, lower, leet, i, extraWords) },
+			func() (int, float64) { return repeatMatch(original, i) },
+			func() (int, float64) { return sequenceMatch(lower, i) },
+			func() (int, float64) { return keyboardMatch(lower, i) },
+			func() (int, float64) { return yearMatch(lower, i) },
+		} {
+			if mn, mg := match(); mn > n || (mn == n && mn > 0 && mg < g) {
+				n, g = mn, mg
+			}
+		}
+
+		if n == 0 {
+			bruteClasses[charClass(original[i])] = true
+			bruteLen++
+			i++
+			continue
+		}
+
+		flushBrute()
+		guesses *= g
+		segments++
+		i += n
+	}
+	flushBrute()
+
+	// Account for the attacker not knowing the order of the patterns.
+	for k := 2; k <= segments; k++ {
+		guesses *= float64(k)
+	}
+	return guesses
+}
+
+const (
+	classDigit = iota
+	classLower
+	classUpper
+	classSymbol
+	classOther
+)
+
+var classCardinality = map[int]int{
+	classDigit:  10,
+	classLower:  26,
+	classUpper:  26,
+	classSymbol: 33,
+	classOther:  100,
+}
+
+func charClass(r rune) int {
+	switch {
+	case r >= '0' && r <= '9':
+		return classDigit
+	case r >= 'a' && r <= 'z':
+		return classLower
+	case r >= 'A' && r <= 'Z':
+		return classUpper
+	case r < unicode.MaxASCII && (unicode.IsPunct(r) || unicode.IsSymbol(r) || r == ' '):
+		return classSymbol
+	default:
+		return classOther
+	}
+}
+
+func dictionaryMatch(original, lower, leet []rune, i int, extraWords []string) (int, float64) {
+	bestLen, bestGuesses := 0, 0.0
+
+	try := func(word string, rank int) {
+		w := []rune(word)
+		if len(w) < 3 || i+len(w) > len(lower) || len(w) < bestLen {
+			return
+		}
+		plain := string(lower[i:i+len(w)]) == word
+		substituted := !plain && string(leet[i:i+len(w)]) == word
+		if !plain && !substituted {
+			return
+		}
+
+		g := float64(rank) * caseVariations(original[i:i+len(w)])
+		if substituted {
+			g *= 2
+		}
+		if len(w) > bestLen || g < bestGuesses {
+			bestLen, bestGuesses = len(w), g
+		}
+	}
+
+	for _, word := range extraWords {
+		try(word, 1)
+	}
+	for rank, word := range commonWords {
+		try(word, rank+1)
+	}
+	return bestLen, bestGuesses
+}
+
+func caseVariations(word []rune) float64 {
+	upper := 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 1
+	case upper == len(word) || (upper == 1 && unicode.IsUpper(word[0])):
+		return 2
+	default:
+		return float64(int(1) << min(upper, 10))
+	}
+}
+
+func repeatMatch(original []rune, i int) (int, float64) {
+	n := 1
+	for i+n < len(original) && original[i+n] == original[i] {
+		n++
+	}
+	if n < 3 {
+		return 0, 0
+	}
+	return n, float64(classCardinality[charClass(original[i])] * n)
+}
+
+func sequenceMatch(lower []rune, i int) (int, float64) {
+	if i+2 >= len(lower) {
+		return 0, 0
+	}
+	delta := lower[i+1] - lower[i]
+	if delta != 1 && delta != -1 {
+		return 0, 0
+	}
+	n := 2
+	for i+n < len(lower) && lower[i+n]-lower[i+n-1] == delta && charClass(lower[i+n]) == charClass(lower[i]) {
+		n++
+	}
+	if n < 3 || charClass(lower[i+1]) != charClass(lower[i]) {
+		return 0, 0
+	}
+
+	base := 26.0
+	switch lower[i] {
+	case 'a', 'z', '0', '1', '9':
+		base = 4
+	default:
+		if charClass(lower[i]) == classDigit {
+			base = 10
+		}
+	}
+	if delta < 0 {
+		base *= 2
+	}
+	return n, base * float64(n)
+}
+
+func keyboardMatch(lower []rune, i int) (int, float64) {
+	best := 0
+	for _, row := range keyboardRows {
+		for _, candidate := range []string{row, reverse(row)} {
+			r := []rune(candidate)
+			for start := range r {
+				n := 0
+				for i+n < len(lower) && start+n < len(r) && lower[i+n] == r[start+n] {
+					n++
+				}
+				if n > best {
+					best = n
+				}
+			}
+		}
+	}
+	if best < 4 {
+		return 0, 0
+	}
+	return best, 20 * float64(best)
+}
+
+func yearMatch(lower []rune, i int) (int, float64) {
+	if i+4 > len(lower) {
+		return 0, 0
+	}
+	year := string(lower[i : i+4])
+	if (strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) &&
+		unicode.IsDigit(lower[i+2]) && unicode.IsDigit(lower[i+3]) {
+		return 4, 120
+	}
+	return 0, 0
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for a, b := 0, len(r)-1; a < b; a, b = a+1, b-1 {
+		r[a], r[b] = r[b], r[a]
+	}
+	return string(r)
+}
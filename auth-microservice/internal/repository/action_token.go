@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const ACTION_TOKEN_COLLECTION = "action_tokens"
+
+func CreateActionToken(ctx context.Context, token *model.ActionToken) error {
+	col := db.GetCollection(db.DB_NAME, ACTION_TOKEN_COLLECTION)
+
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+
+	_, err := col.InsertOne(ctx, token)
+	return err
+}
+
+// GetActionToken returns an unused, unexpired token without consuming it.
+func GetActionToken(ctx context.Context, tokenHash, purpose string) (*model.ActionToken, error) {
+	col := db.GetCollection(db.DB_NAME, ACTION_TOKEN_COLLECTION)
+
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+		"used_at":    bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+
+	var token model.ActionToken
+	if err := col.FindOne(ctx, filter).Decode(&token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ConsumeActionToken atomically marks an unused, unexpired token as used and
+// returns it. A token can only be consumed once.
+func ConsumeActionToken(ctx context.Context, tokenHash, purpose string) (*model.ActionToken, error) {
+	col := db.GetCollection(db.DB_NAME, ACTION_TOKEN_COLLECTION)
+
+	now := time.Now()
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+		"used_at":    bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"used_at": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var token model.ActionToken
+	err := col.FindOneAndUpdate(ctx, filter, update, opts).Decode(&token)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// InvalidateActionTokens marks every outstanding token of the given purpose for
+// a user as used, so that only the most recently issued one stays valid.
+func InvalidateActionTokens(ctx context.Context, userID primitive.ObjectID, purpose string) error {
+	col := db.GetCollection(db.DB_NAME, ACTION_TOKEN_COLLECTION)
+
+	filter := bson.M{
+		"user_id": userID,
+		"purpose": purpose,
+		"used_at": bson.M{"$exists": false},
+	}
+	update := bson.M{"$set": bson.M{"used_at": time.Now()}}
+
+	_, err := col.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// InvalidateActionTokensIssuedAfter marks the outstanding tokens of the given
+// purpose that were issued after since as used, leaving older ones valid.
+func InvalidateActionTokensIssuedAfter(ctx context.Context, userID primitive.ObjectID, purpose string, since time.Time) error {
+	col := db.GetCollection(db.DB_NAME, ACTION_TOKEN_COLLECTION)
+
+	filter := bson.M{
+		"user_id":    userID,
+		"purpose":    purpose,
+		"used_at":    bson.M{"$exists": false},
+		"created_at": bson.M{"$gt": since},
+	}
+	update := bson.M{"$set": bson.M{"used_at": time.Now()}}
+
+	_, err := col.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func DeleteUserActionTokens(ctx context.Context, userID primitive.ObjectID) error {
+	col := db.GetCollection(db.DB_NAME, ACTION_TOKEN_COLLECTION)
+
+	_, err := col.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const ATTRIBUTE_DEFINITION_COLLECTION = "attribute_definitions"
+
+// UpsertAttributeDefinition creates the definition or replaces the one with
+// the same name.
+func UpsertAttributeDefinition(ctx context.Context, def *model.AttributeDefinition) error {
+	col := db.GetCollection(db.DB_NAME, ATTRIBUTE_DEFINITION_COLLECTION)
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"type":          def.Type,
+			"description":   def.Description,
+			"required":      def.Required,
+			"pattern":       def.Pattern,
+			"user_editable": def.UserEditable,
+			"updated_at":    now,
+		},
+		"$setOnInsert": bson.M{
+			"_id":        primitive.NewObjectID(),
+			"created_at": now,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	return col.FindOneAndUpdate(ctx, bson.M{"name": def.Name}, update, opts).Decode(def)
+}
+
+func ListAttributeDefinitions(ctx context.Context) ([]model.AttributeDefinition, error) {
+	col := db.GetCollection(db.DB_NAME, ATTRIBUTE_DEFINITION_COLLECTION)
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := col.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	defs := []model.AttributeDefinition{}
+	if err := cursor.All(ctx, &defs); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// DeleteAttributeDefinition removes the definition and the attribute's value
+// from every user. It returns mongo.ErrNoDocuments if there is no such
+// definition.
+func DeleteAttributeDefinition(ctx context.Context, name string) error {
+	col := db.GetCollection(db.DB_NAME, ATTRIBUTE_DEFINITION_COLLECTION)
+
+	result, err := col.DeleteOne(ctx, bson.M{"name": name})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	field := "attributes." + name
+	_, err = db.GetUserCollection().UpdateMany(ctx,
+		bson.M{field: bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{field: ""}},
+	)
+	return err
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const AUDIT_COLLECTION = "audit_logs"
+
+func RecordAudit(ctx context.Context, entry *model.AuditEntry) error {
+	col := db.GetCollection(db.DB_NAME, AUDIT_COLLECTION)
+
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+
+	_, err := col.InsertOne(ctx, entry)
+	return err
+}
+
+// ListUserAuditEntries returns the entries about the user, newest first.
+// Entries where the user only acted on someone else are left out, since their
+// details hold the other user's data.
+func ListUserAuditEntries(ctx context.Context, userID primitive.ObjectID) ([]model.AuditEntry, error) {
+	col := db.GetCollection(db.DB_NAME, AUDIT_COLLECTION)
+
+	filter := bson.M{"target_user_id": userID}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []model.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// RedactUserAuditEntries removes the details of the entries about a user, which
+// can hold personal data such as emails, names and suspension reasons. The
+// entries themselves are kept as a record of what was done.
+func RedactUserAuditEntries(ctx context.Context, userID primitive.ObjectID) error {
+	col := db.GetCollection(db.DB_NAME, AUDIT_COLLECTION)
+
+	_, err := col.UpdateMany(ctx,
+		bson.M{"target_user_id": userID, "details": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"details": ""}},
+	)
+	return err
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func EnsureIndexes(ctx context.Context) error {
+	if err := ensureUniqueEmailIndex(ctx); err != nil {
+		return err
+	}
+
+	users := db.GetUserCollection()
+	_, err := users.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "purge_after", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "locked_at", Value: -1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{Keys: bson.D{{Key: "roles", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	actionTokens := db.GetCollection(db.DB_NAME, ACTION_TOKEN_COLLECTION)
+	_, err = actionTokens.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	sessions := db.GetCollection(db.DB_NAME, SESSION_COLLECTION)
+	_, err = sessions.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+
+	audit := db.GetCollection(db.DB_NAME, AUDIT_COLLECTION)
+	_, err = audit.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "target_user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	logins := db.GetCollection(db.DB_NAME, LOGIN_HISTORY_COLLECTION)
+	_, err = logins.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	invitations := db.GetCollection(db.DB_NAME, INVITATION_COLLECTION)
+	_, err = invitations.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	attributes := db.GetCollection(db.DB_NAME, ATTRIBUTE_DEFINITION_COLLECTION)
+	_, err = attributes.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	policies := db.GetCollection(db.DB_NAME, POLICY_COLLECTION)
+	_, err = policies.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "type", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "published_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	acceptances := db.GetCollection(db.DB_NAME, POLICY_ACCEPTANCE_COLLECTION)
+	_, err = acceptances.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "accepted_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	roles := db.GetCollection(db.DB_NAME, ROLE_COLLECTION)
+	_, err = roles.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// emailIndexMissing is set while the unique email index could not be
+// created, see ensureUniqueEmailIndex.
+var emailIndexMissing atomic.Bool
+
+// ensureUniqueEmailIndex creates the index that keeps active emails unique.
+// Data written before emails were normalized can hold several active accounts
+// with the same address, on which creating the index would fail. Those are
+// logged for manual review and the index is left out until they are
+// resolved, so the service still starts. Until then, writes that set an
+// email check for duplicates themselves, see checkEmailUnique.
+func ensureUniqueEmailIndex(ctx context.Context) error {
+	users := db.GetUserCollection()
+	emailIndexMissing.Store(false)
+
+	cursor, err := users.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deleted": false}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$email",
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var collisions []struct {
+		Email string               `bson:"_id"`
+		IDs   []primitive.ObjectID `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &collisions); err != nil {
+		return err
+	}
+	if len(collisions) > 0 {
+		for _, c := range collisions {
+			ids := make([]string, len(c.IDs))
+			for i, id := range c.IDs {
+				ids[i] = id.Hex()
+			}
+			log.Printf("index: email %q is used by %d active users: %s", c.Email, len(c.IDs), strings.Join(ids, ", "))
+		}
+		log.Printf("index: email_unique_active not created; rename or delete the accounts above and restart")
+		log.Printf("index: until then, emails are checked for duplicates before each write")
+		emailIndexMissing.Store(true)
+		return nil
+	}
+
+	_, err = users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().
+			SetName("email_unique_active").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"deleted": false}),
+	})
+	return err
+}
+
+// checkEmailUnique stands in for the unique email index while it is missing:
+// it fails with a duplicate key error if an active user other than exceptID
+// has email, as the index would. With the index in place it does nothing.
+func checkEmailUnique(ctx context.Context, email string, exceptID primitive.ObjectID) error {
+	if !emailIndexMissing.Load() {
+		return nil
+	}
+	taken, err := IsEmailTaken(ctx, email, exceptID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return mongo.WriteException{WriteErrors: []mongo.WriteError{{
+			Code:    11000,
+			Message: "duplicate key error: email " + email + " is already in use",
+		}}}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const INVITATION_COLLECTION = "invitations"
+
+func CreateInvitation(ctx context.Context, invitation *model.Invitation) error {
+	col := db.GetCollection(db.DB_NAME, INVITATION_COLLECTION)
+
+	invitation.ID = primitive.NewObjectID()
+	invitation.CreatedAt = time.Now()
+
+	_, err := col.InsertOne(ctx, invitation)
+	return err
+}
+
+func pendingInvitationFilter(now time.Time) bson.M {
+	return bson.M{
+		"accepted_at": bson.M{"$exists": false},
+		"revoked_at":  bson.M{"$exists": false},
+		"expires_at":  bson.M{"$gt": now},
+	}
+}
+
+// ClaimInvitation atomically marks the pending invitation for the token hash
+// as accepted by userID and returns it. It returns mongo.ErrNoDocuments if
+// there is no such invitation or it can no longer be accepted.
+func ClaimInvitation(ctx context.Context, tokenHash string, userID primitive.ObjectID) (*model.Invitation, error) {
+	col := db.GetCollection(db.DB_NAME, INVITATION_COLLECTION)
+
+	now := time.Now()
+	filter := pendingInvitationFilter(now)
+	filter["token_hash"] = tokenHash
+	update := bson.M{"$set": bson.M{"accepted_at": now, "user_id": userID}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var invitation model.Invitation
+	if err := col.FindOneAndUpdate(ctx, filter, update, opts).Decode(&invitation); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ReleaseInvitation undoes ClaimInvitation when the account could not be
+// created, so the invitation can be accepted again.
+func ReleaseInvitation(ctx context.Context, invitationID, userID primitive.ObjectID) error {
+	col := db.GetCollection(db.DB_NAME, INVITATION_COLLECTION)
+
+	_, err := col.UpdateOne(ctx,
+		bson.M{"_id": invitationID, "user_id": userID},
+		bson.M{"$unset": bson.M{"accepted_at": "", "user_id": ""}},
+	)
+	return err
+}
+
+// RevokeInvitation revokes a pending invitation and returns it. It returns
+// mongo.ErrNoDocuments if there is no pending invitation with that id.
+func RevokeInvitation(ctx context.Context, invitationID primitive.ObjectID) (*model.Invitation, error) {
+	col := db.GetCollection(db.DB_NAME, INVITATION_COLLECTION)
+
+	now := time.Now()
+	filter := pendingInvitationFilter(now)
+	filter["_id"] = invitationID
+	update := bson.M{"$set": bson.M{"revoked_at": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var invitation model.Invitation
+	if err := col.FindOneAndUpdate(ctx, filter, update, opts).Decode(&invitation); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// RevokePendingInvitationsForEmail revokes every pending invitation for the
+// address so that only the newest one can be accepted.
+func RevokePendingInvitationsForEmail(ctx context.Context, email string) error {
+	col := db.GetCollection(db.DB_NAME, INVITATION_COLLECTION)
+
+	filter := pendingInvitationFilter(time.Now())
+	filter["email"] = email
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+
+	_, err := col.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// ListInvitations returns invitations newest first, optionally restricted to
+// one model.InvitationStatus* value.
+func ListInvitations(ctx context.Context, status string, page, limit int64) ([]*model.Invitation, int64, error) {
+	col := db.GetCollection(db.DB_NAME, INVITATION_COLLECTION)
+
+	now := time.Now()
+	filter := bson.M{}
+	switch status {
+	case model.InvitationStatusPending:
+		filter = pendingInvitationFilter(now)
+	case model.InvitationStatusAccepted:
+		filter["accepted_at"] = bson.M{"$exists": true}
+	case model.InvitationStatusRevoked:
+		filter["revoked_at"] = bson.M{"$exists": true}
+	case model.InvitationStatusExpired:
+		filter["accepted_at"] = bson.M{"$exists": false}
+		filter["revoked_at"] = bson.M{"$exists": false}
+		filter["expires_at"] = bson.M{"$lte": now}
+	}
+
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	total, err := col.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var invitations []*model.Invitation
+	if err := cursor.All(ctx, &invitations); err != nil {
+		return nil, 0, err
+	}
+	return invitations, total, nil
+}
+
+// RedactUserInvitations replaces the address on the invitations a user
+// accepted with the placeholder AnonymizeUser gives the account.
+func RedactUserInvitations(ctx context.Context, userID primitive.ObjectID) error {
+	col := db.GetCollection(db.DB_NAME, INVITATION_COLLECTION)
+
+	_, err := col.UpdateMany(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"email": "deleted-" + userID.Hex() + "@invalid.invalid"}},
+	)
+	return err
+}
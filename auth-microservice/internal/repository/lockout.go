@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RecordFailedLogin increments the user's consecutive failed login counter
+// and returns the new value.
+func RecordFailedLogin(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	collection := db.GetUserCollection()
+
+	update := bson.M{
+		"$inc": bson.M{"failed_login_attempts": 1},
+		"$set": bson.M{"last_failed_login_at": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"failed_login_attempts": 1})
+
+	var user model.User
+	err := collection.FindOneAndUpdate(ctx, bson.M{"_id": userID, "deleted": false}, update, opts).Decode(&user)
+	return user.FailedLoginAttempts, err
+}
+
+// LockUser locks the account until the given time, or until it is unlocked
+// when permanent is set.
+func LockUser(ctx context.Context, userID primitive.ObjectID, until time.Time, permanent bool) error {
+	collection := db.GetUserCollection()
+
+	set := bson.M{"locked_at": time.Now()}
+	if permanent {
+		set["locked"] = true
+	} else {
+		set["locked_until"] = until
+	}
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": userID, "deleted": false}, bson.M{"$set": set})
+	return err
+}
+
+// ClearLockout resets the failed login counter and lifts any lock.
+func ClearLockout(ctx context.Context, userID primitive.ObjectID) error {
+	collection := db.GetUserCollection()
+
+	update := bson.M{"$unset": bson.M{
+		"failed_login_attempts": "",
+		"last_failed_login_at":  "",
+		"locked":                "",
+		"locked_at":             "",
+		"locked_until":          "",
+	}}
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": userID, "deleted": false}, update)
+	return err
+}
+
+// ListLockedUsers returns users that are permanently locked or whose lock
+// has not expired yet, most recently locked first.
+func ListLockedUsers(ctx context.Context, page, limit int64) ([]*model.User, int64, error) {
+	collection := db.GetUserCollection()
+
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	filter := bson.M{
+		"deleted": false,
+		"$or": bson.A{
+			bson.M{"locked": true},
+			bson.M{"locked_until": bson.M{"$gt": time.Now()}},
+		},
+	}
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "locked_at", Value: -1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*model.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const LOGIN_HISTORY_COLLECTION = "login_history"
+
+func RecordLogin(ctx context.Context, event *model.LoginEvent) error {
+	col := db.GetCollection(db.DB_NAME, LOGIN_HISTORY_COLLECTION)
+
+	event.ID = primitive.NewObjectID()
+	event.CreatedAt = time.Now()
+
+	_, err := col.InsertOne(ctx, event)
+	return err
+}
+
+func ListUserLogins(ctx context.Context, userID primitive.ObjectID) ([]model.LoginEvent, error) {
+	col := db.GetCollection(db.DB_NAME, LOGIN_HISTORY_COLLECTION)
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := col.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []model.LoginEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func DeleteUserLogins(ctx context.Context, userID primitive.ObjectID) error {
+	col := db.GetCollection(db.DB_NAME, LOGIN_HISTORY_COLLECTION)
+
+	_, err := col.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/utils"
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RunMigrations brings documents written by older versions of the service up
+// to date. Every step must be safe to run on each startup.
+func RunMigrations(ctx context.Context, deletionGracePeriod time.Duration) error {
+	users := db.GetUserCollection()
+
+	// Accounts created before email verification existed are grandfathered in.
+	result, err := users.UpdateMany(ctx,
+		bson.M{"email_verified": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"email_verified": true}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("migration: marked %d existing users as email verified", result.ModifiedCount)
+	}
+
+	if err := normalizeEmails(ctx); err != nil {
+		return err
+	}
+
+	if err := migrateSingleRoles(ctx); err != nil {
+		return err
+	}
+
+	if err := backfillPurgeAfter(ctx, deletionGracePeriod); err != nil {
+		return err
+	}
+
+	return EnsureBuiltInRoles(ctx)
+}
+
+// normalizeEmails rewrites addresses stored before emails were normalized.
+// It must run before the unique email index is created. Addresses that
+// collide with another account once normalized are left as they are and
+// logged for manual review.
+func normalizeEmails(ctx context.Context) error {
+	users := db.GetUserCollection()
+
+	filter := bson.M{"email": bson.M{"$regex": `[A-Z]|^\s|\s$|[^\x00-\x7F]`}}
+	cursor, err := users.Find(ctx, filter, options.Find().SetProjection(bson.M{"email": 1, "deleted": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	updated := 0
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID      primitive.ObjectID `bson:"_id"`
+			Email   string             `bson:"email"`
+			Deleted bool               `bson:"deleted"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+
+		normalized, err := utils.NormalizeEmail(doc.Email)
+		if err != nil {
+			log.Printf("migration: user %s has an invalid email %q, skipping", doc.ID.Hex(), doc.Email)
+			continue
+		}
+
+		if !doc.Deleted {
+			taken, err := users.CountDocuments(ctx, bson.M{"email": normalized, "deleted": false, "_id": bson.M{"$ne": doc.ID}})
+			if err != nil {
+				return err
+			}
+			if taken > 0 {
+				log.Printf("migration: user %s email %q collides with another account once normalized, skipping", doc.ID.Hex(), doc.Email)
+				continue
+			}
+		}
+
+		if _, err := users.UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{"$set": bson.M{"email": normalized}}); err != nil {
+			return err
+		}
+		updated++
+	}
+	if updated > 0 {
+		log.Printf("migration: normalized %d user emails", updated)
+	}
+
+	return cursor.Err()
+}
+
+// migrateSingleRoles turns the single role string stored by older versions
+// into a roles array. Users without a role get the default one.
+func migrateSingleRoles(ctx context.Context) error {
+	users := db.GetUserCollection()
+
+	result, err := users.UpdateMany(ctx,
+		bson.M{"roles": bson.M{"$exists": false}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"roles": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$role", ""}}, ""}},
+				bson.A{model.RoleUser},
+				bson.A{"$role"},
+			}}}}},
+			{{Key: "$unset", Value: "role"}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("migration: moved the role of %d users into roles", result.ModifiedCount)
+	}
+	return nil
+}
+
+// backfillPurgeAfter schedules accounts deleted before the purger existed,
+// which the purger would otherwise never pick up. They are purged one grace
+// period after their deletion, or after now if that was not recorded.
+func backfillPurgeAfter(ctx context.Context, gracePeriod time.Duration) error {
+	users := db.GetUserCollection()
+
+	result, err := users.UpdateMany(ctx,
+		bson.M{"deleted": true, "purge_after": bson.M{"$exists": false}, "anonymized_at": bson.M{"$exists": false}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"purge_after": bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$deleted_at", time.Now()}},
+				gracePeriod.Milliseconds(),
+			}}}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("migration: scheduled %d previously deleted users for purging", result.ModifiedCount)
+	}
+	return nil
+}
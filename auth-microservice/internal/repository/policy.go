@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	POLICY_COLLECTION            = "policies"
+	POLICY_ACCEPTANCE_COLLECTION = "policy_acceptances"
+)
+
+// CreatePolicy publishes a new policy version. The unique (type, version)
+// index rejects versions that already exist.
+func CreatePolicy(ctx context.Context, policy *model.PolicyDocument) error {
+	col := db.GetCollection(db.DB_NAME, POLICY_COLLECTION)
+
+	policy.ID = primitive.NewObjectID()
+	policy.PublishedAt = time.Now()
+
+	_, err := col.InsertOne(ctx, policy)
+	return err
+}
+
+func GetPolicyVersion(ctx context.Context, policyType, version string) (*model.PolicyDocument, error) {
+	col := db.GetCollection(db.DB_NAME, POLICY_COLLECTION)
+
+	var policy model.PolicyDocument
+	if err := col.FindOne(ctx, bson.M{"type": policyType, "version": version}).Decode(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ListPolicyVersions returns every published version of the type, newest
+// first.
+func ListPolicyVersions(ctx context.Context, policyType string) ([]model.PolicyDocument, error) {
+	col := db.GetCollection(db.DB_NAME, POLICY_COLLECTION)
+
+	opts := options.Find().SetSort(bson.D{{Key: "published_at", Value: -1}})
+	cursor, err := col.Find(ctx, bson.M{"type": policyType}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	policies := []model.PolicyDocument{}
+	if err := cursor.All(ctx, &policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// LatestPolicies returns the most recently published version of each policy
+// type, or only of the mandatory versions when mandatoryOnly is set.
+func LatestPolicies(ctx context.Context, mandatoryOnly bool) ([]model.PolicyDocument, error) {
+	col := db.GetCollection(db.DB_NAME, POLICY_COLLECTION)
+
+	match := bson.M{}
+	if mandatoryOnly {
+		match["mandatory"] = true
+	}
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$sort": bson.D{{Key: "published_at", Value: -1}}},
+		bson.M{"$group": bson.M{"_id": "$type", "doc": bson.M{"$first": "$$ROOT"}}},
+		bson.M{"$replaceRoot": bson.M{"newRoot": "$doc"}},
+		bson.M{"$sort": bson.D{{Key: "type", Value: 1}}},
+	}
+
+	cursor, err := col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	policies := []model.PolicyDocument{}
+	if err := cursor.All(ctx, &policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// RecordPolicyAcceptance stores the proof of acceptance and updates the
+// user's accepted version of the policy type.
+func RecordPolicyAcceptance(ctx context.Context, acceptance *model.PolicyAcceptance, policy *model.PolicyDocument) error {
+	col := db.GetCollection(db.DB_NAME, POLICY_ACCEPTANCE_COLLECTION)
+
+	acceptance.ID = primitive.NewObjectID()
+	acceptance.PolicyID = policy.ID
+	acceptance.Type = policy.Type
+	acceptance.Version = policy.Version
+	acceptance.AcceptedAt = time.Now()
+
+	if _, err := col.InsertOne(ctx, acceptance); err != nil {
+		return err
+	}
+
+	_, err := db.GetUserCollection().UpdateOne(ctx,
+		bson.M{"_id": acceptance.UserID},
+		bson.M{"$set": bson.M{"accepted_policies." + policy.Type: model.AcceptedPolicy{
+			Version:     policy.Version,
+			PublishedAt: policy.PublishedAt,
+			AcceptedAt:  acceptance.AcceptedAt,
+		}}},
+	)
+	return err
+}
+
+// HasAcceptedPolicies reports whether the user accepted each of the given
+// policies or a version of the same type published after it.
+func HasAcceptedPolicies(ctx context.Context, userID primitive.ObjectID, required []model.PolicyDocument) (bool, error) {
+	if len(required) == 0 {
+		return true, nil
+	}
+
+	filter := bson.M{"_id": userID}
+	for _, policy := range required {
+		filter["accepted_policies."+policy.Type+".published_at"] = bson.M{"$gte": policy.PublishedAt}
+	}
+
+	count, err := db.GetUserCollection().CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func ListUserPolicyAcceptances(ctx context.Context, userID primitive.ObjectID) ([]model.PolicyAcceptance, error) {
+	col := db.GetCollection(db.DB_NAME, POLICY_ACCEPTANCE_COLLECTION)
+
+	opts := options.Find().SetSort(bson.D{{Key: "accepted_at", Value: -1}})
+	cursor, err := col.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	acceptances := []model.PolicyAcceptance{}
+	if err := cursor.All(ctx, &acceptances); err != nil {
+		return nil, err
+	}
+	return acceptances, nil
+}
+
+func DeleteUserPolicyAcceptances(ctx context.Context, userID primitive.ObjectID) error {
+	col := db.GetCollection(db.DB_NAME, POLICY_ACCEPTANCE_COLLECTION)
+
+	_, err := col.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const ROLE_COLLECTION = "roles"
+
+// EnsureBuiltInRoles creates the built-in roles and grants the admin role
+// every known permission, so permissions added in newer versions reach it
+// on the next startup.
+func EnsureBuiltInRoles(ctx context.Context) error {
+	col := db.GetCollection(db.DB_NAME, ROLE_COLLECTION)
+
+	now := time.Now()
+	opts := options.Update().SetUpsert(true)
+
+	_, err := col.UpdateOne(ctx, bson.M{"name": model.RoleAdmin}, bson.M{
+		"$set": bson.M{
+			"permissions": model.Permissions,
+			"built_in":    true,
+			"updated_at":  now,
+		},
+		"$setOnInsert": bson.M{
+			"description": "Full access to every administrative operation",
+			"created_at":  now,
+		},
+	}, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = col.UpdateOne(ctx, bson.M{"name": model.RoleUser}, bson.M{
+		"$set": bson.M{"built_in": true},
+		"$setOnInsert": bson.M{
+			"description": "Default role for new accounts",
+			"permissions": []string{},
+			"created_at":  now,
+			"updated_at":  now,
+		},
+	}, opts)
+	return err
+}
+
+// CreateRole stores a new role. The unique name index rejects duplicates.
+func CreateRole(ctx context.Context, role *model.Role) error {
+	col := db.GetCollection(db.DB_NAME, ROLE_COLLECTION)
+
+	role.ID = primitive.NewObjectID()
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = role.CreatedAt
+
+	_, err := col.InsertOne(ctx, role)
+	return err
+}
+
+func GetRoleByName(ctx context.Context, name string) (*model.Role, error) {
+	col := db.GetCollection(db.DB_NAME, ROLE_COLLECTION)
+
+	var role model.Role
+	if err := col.FindOne(ctx, bson.M{"name": name}).Decode(&role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// UpdateRole replaces the description and permissions of the role and
+// returns the updated document, or mongo.ErrNoDocuments if there is no such
+// role.
+func UpdateRole(ctx context.Context, name, description string, permissions []string) (*model.Role, error) {
+	col := db.GetCollection(db.DB_NAME, ROLE_COLLECTION)
+
+	update := bson.M{"$set": bson.M{
+		"description": description,
+		"permissions": permissions,
+		"updated_at":  time.Now(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var role model.Role
+	if err := col.FindOneAndUpdate(ctx, bson.M{"name": name}, update, opts).Decode(&role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// DeleteRole removes a role that is not built in. It returns
+// mongo.ErrNoDocuments if there is no such role.
+func DeleteRole(ctx context.Context, name string) error {
+	col := db.GetCollection(db.DB_NAME, ROLE_COLLECTION)
+
+	result, err := col.DeleteOne(ctx, bson.M{"name": name, "built_in": bson.M{"$ne": true}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// GetRolesByName returns the roles that exist among names.
+func GetRolesByName(ctx context.Context, names []string) ([]model.Role, error) {
+	col := db.GetCollection(db.DB_NAME, ROLE_COLLECTION)
+
+	cursor, err := col.Find(ctx, bson.M{"name": bson.M{"$in": names}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	roles := []model.Role{}
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func ListRoles(ctx context.Context) ([]model.Role, error) {
+	col := db.GetCollection(db.DB_NAME, ROLE_COLLECTION)
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := col.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	roles := []model.Role{}
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// RoleNamesWithPermission returns the names of the roles that grant the
+// permission.
+func RoleNamesWithPermission(ctx context.Context, permission string) ([]string, error) {
+	col := db.GetCollection(db.DB_NAME, ROLE_COLLECTION)
+
+	values, err := col.Distinct(ctx, "name", bson.M{"permissions": permission})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(values))
+	for _, v := range values {
+		if name, ok := v.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
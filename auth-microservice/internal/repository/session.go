@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const SESSION_COLLECTION = "sessions"
+
+func CreateSession(ctx context.Context, session *model.Session) error {
+	col := db.GetCollection(db.DB_NAME, SESSION_COLLECTION)
+
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = time.Now()
+
+	_, err := col.InsertOne(ctx, session)
+	return err
+}
+
+func IsSessionActive(ctx context.Context, sessionID primitive.ObjectID) (bool, error) {
+	col := db.GetCollection(db.DB_NAME, SESSION_COLLECTION)
+
+	count, err := col.CountDocuments(ctx, bson.M{
+		"_id":        sessionID,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+	})
+	return count > 0, err
+}
+
+func RevokeSession(ctx context.Context, sessionID primitive.ObjectID) error {
+	col := db.GetCollection(db.DB_NAME, SESSION_COLLECTION)
+
+	filter := bson.M{"_id": sessionID, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+
+	_, err := col.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// RevokeUserSessions revokes every active session of a user except keepID.
+// Pass primitive.NilObjectID to revoke all of them.
+func RevokeUserSessions(ctx context.Context, userID, keepID primitive.ObjectID) error {
+	col := db.GetCollection(db.DB_NAME, SESSION_COLLECTION)
+
+	filter := bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}}
+	if !keepID.IsZero() {
+		filter["_id"] = bson.M{"$ne": keepID}
+	}
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+
+	_, err := col.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// RevokeSuspendedUserSessions revokes every active session of a suspended
+// user and records why, see SessionRevokeReason.
+func RevokeSuspendedUserSessions(ctx context.Context, userID primitive.ObjectID) error {
+	col := db.GetCollection(db.DB_NAME, SESSION_COLLECTION)
+
+	filter := bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{
+		"revoked_at":     time.Now(),
+		"revoked_reason": model.SessionRevokedSuspended,
+	}}
+
+	_, err := col.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// SessionRevokeReason returns the reason recorded when the session was
+// revoked, or "" if none was recorded or the session does not exist.
+func SessionRevokeReason(ctx context.Context, sessionID primitive.ObjectID) (string, error) {
+	col := db.GetCollection(db.DB_NAME, SESSION_COLLECTION)
+
+	var session model.Session
+	err := col.FindOne(ctx, bson.M{"_id": sessionID}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return session.RevokedReason, nil
+}
+
+func DeleteUserSessions(ctx context.Context, userID primitive.ObjectID) error {
+	col := db.GetCollection(db.DB_NAME, SESSION_COLLECTION)
+
+	_, err := col.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func ListUserSessions(ctx context.Context, userID primitive.ObjectID) ([]model.Session, error) {
+	col := db.GetCollection(db.DB_NAME, SESSION_COLLECTION)
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := col.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []model.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
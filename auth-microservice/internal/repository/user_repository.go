@@ -9,6 +9,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -17,6 +18,9 @@ func CreateUser(user *model.User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if err := checkEmailUnique(ctx, user.Email, user.ID); err != nil {
+		return err
+	}
 	_, err := collection.InsertOne(ctx, user)
 	return err
 }
@@ -53,6 +57,445 @@ func UpdateUser(userID primitive.ObjectID, updateData map[string]interface{}) er
 	return err
 }
 
+// GetUserByIDAnyState returns the user whether or not it has been deleted.
+func GetUserByIDAnyState(ctx context.Context, id primitive.ObjectID) (*model.User, error) {
+	collection := db.GetUserCollection()
+
+	var user model.User
+	if err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUserFields sets and unsets fields of an active user and reports
+// mongo.ErrNoDocuments if there is no such user.
+func UpdateUserFields(ctx context.Context, userID primitive.ObjectID, set bson.M, unset []string) error {
+	collection := db.GetUserCollection()
+
+	if email, ok := set["email"].(string); ok {
+		if err := checkEmailUnique(ctx, email, userID); err != nil {
+			return err
+		}
+	}
+
+	set["updated_at"] = time.Now()
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		fields := bson.M{}
+		for _, field := range unset {
+			fields[field] = ""
+		}
+		update["$unset"] = fields
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": userID, "deleted": false}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// CountUsersWithRoles returns the number of active users holding one of the
+// roles.
+func CountUsersWithRoles(ctx context.Context, roles []string) (int64, error) {
+	collection := db.GetUserCollection()
+
+	return collection.CountDocuments(ctx, bson.M{"roles": bson.M{"$in": roles}, "deleted": false})
+}
+
+// CountRoleHolders returns the number of users, deleted or not, that hold
+// the role.
+func CountRoleHolders(ctx context.Context, role string) (int64, error) {
+	collection := db.GetUserCollection()
+
+	return collection.CountDocuments(ctx, bson.M{"roles": role})
+}
+
+func IsEmailTaken(ctx context.Context, email string, exceptID primitive.ObjectID) (bool, error) {
+	collection := db.GetUserCollection()
+
+	count, err := collection.CountDocuments(ctx, bson.M{
+		"email":   email,
+		"deleted": false,
+		"_id":     bson.M{"$ne": exceptID},
+	})
+	return count > 0, err
+}
+
+func SetPendingEmail(ctx context.Context, userID primitive.ObjectID, email string) error {
+	collection := db.GetUserCollection()
+
+	filter := bson.M{"_id": userID, "deleted": false}
+	update := bson.M{"$set": bson.M{"pending_email": email, "updated_at": time.Now()}}
+
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// ConfirmPendingEmail replaces the email with the pending one if it still
+// matches. The unique email index rejects the update if another active user
+// has claimed the address in the meantime.
+func ConfirmPendingEmail(ctx context.Context, userID primitive.ObjectID, email string) (bool, error) {
+	collection := db.GetUserCollection()
+
+	if err := checkEmailUnique(ctx, email, userID); err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": userID, "deleted": false, "pending_email": email}
+	update := bson.M{
+		"$set": bson.M{
+			"email":             email,
+			"email_verified":    true,
+			"email_verified_at": time.Now(),
+			"updated_at":        time.Now(),
+		},
+		"$unset": bson.M{"pending_email": ""},
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func RevertEmail(ctx context.Context, userID primitive.ObjectID, email string) error {
+	collection := db.GetUserCollection()
+
+	if err := checkEmailUnique(ctx, email, userID); err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": userID, "deleted": false}
+	update := bson.M{
+		"$set": bson.M{
+			"email":          email,
+			"email_verified": true,
+			"updated_at":     time.Now(),
+		},
+		"$unset": bson.M{"pending_email": ""},
+	}
+
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// MarkUserDeleted soft-deletes a user. The account can be restored until
+// purgeAfter, after which the purger removes its personal data.
+func MarkUserDeleted(ctx context.Context, userID primitive.ObjectID, purgeAfter time.Time) error {
+	collection := db.GetUserCollection()
+
+	now := time.Now()
+	filter := bson.M{"_id": userID, "deleted": false}
+	update := bson.M{"$set": bson.M{
+		"deleted":     true,
+		"deleted_at":  now,
+		"purge_after": purgeAfter,
+		"updated_at":  now,
+	}}
+
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// SuspendUser blocks the account until it is unsuspended or, when until is
+// not zero, until that time has passed.
+func SuspendUser(ctx context.Context, userID, suspendedBy primitive.ObjectID, reason string, until time.Time) error {
+	collection := db.GetUserCollection()
+
+	now := time.Now()
+	set := bson.M{
+		"suspended":         true,
+		"suspended_at":      now,
+		"suspended_by":      suspendedBy,
+		"suspension_reason": reason,
+		"updated_at":        now,
+	}
+	unset := bson.M{}
+	if until.IsZero() {
+		unset["suspended_until"] = ""
+	} else {
+		set["suspended_until"] = until
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": userID, "deleted": false}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func UnsuspendUser(ctx context.Context, userID primitive.ObjectID) error {
+	collection := db.GetUserCollection()
+
+	update := bson.M{
+		"$set": bson.M{"suspended": false, "updated_at": time.Now()},
+		"$unset": bson.M{
+			"suspended_at":      "",
+			"suspended_until":   "",
+			"suspended_by":      "",
+			"suspension_reason": "",
+		},
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": userID, "deleted": false}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// GetRestorableUserByEmail returns the most recently deleted account for the
+// email that is still inside its grace period.
+func GetRestorableUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	collection := db.GetUserCollection()
+
+	filter := bson.M{
+		"email":       email,
+		"deleted":     true,
+		"purge_after": bson.M{"$gt": time.Now()},
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+
+	var user model.User
+	if err := collection.FindOne(ctx, filter, opts).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func RestoreUser(ctx context.Context, userID primitive.ObjectID) error {
+	collection := db.GetUserCollection()
+
+	filter := bson.M{"_id": userID, "deleted": true, "purge_after": bson.M{"$gt": time.Now()}}
+
+	if emailIndexMissing.Load() {
+		var user model.User
+		if err := collection.FindOne(ctx, filter).Decode(&user); err != nil {
+			return err
+		}
+		if err := checkEmailUnique(ctx, user.Email, userID); err != nil {
+			return err
+		}
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"deleted": false, "updated_at": time.Now()},
+		"$unset": bson.M{"deleted_at": "", "purge_after": ""},
+	}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func ListPendingDeletions(ctx context.Context, page, limit int64) ([]*model.User, int64, error) {
+	collection := db.GetUserCollection()
+
+	if limit <= 0 {
+		limit = 10
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	filter := bson.M{"deleted": true, "purge_after": bson.M{"$exists": true}}
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "purge_after", Value: 1}}).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*model.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// FindUsersDueForPurge returns up to limit deleted users whose grace period
+// ended by now, leaving out those in exclude.
+func FindUsersDueForPurge(ctx context.Context, now time.Time, exclude []primitive.ObjectID, limit int64) ([]primitive.ObjectID, error) {
+	collection := db.GetUserCollection()
+
+	filter := bson.M{"deleted": true, "purge_after": bson.M{"$lte": now}}
+	if len(exclude) > 0 {
+		filter["_id"] = bson.M{"$nin": exclude}
+	}
+	opts := options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var ids []primitive.ObjectID
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		ids = append(ids, doc.ID)
+	}
+	return ids, cursor.Err()
+}
+
+// SetPurgeAfter moves the purge date of a deleted user, e.g. to expedite it.
+func SetPurgeAfter(ctx context.Context, userID primitive.ObjectID, purgeAfter time.Time) error {
+	collection := db.GetUserCollection()
+
+	filter := bson.M{"_id": userID, "deleted": true, "purge_after": bson.M{"$exists": true}}
+	update := bson.M{"$set": bson.M{"purge_after": purgeAfter}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func HardDeleteUser(ctx context.Context, userID primitive.ObjectID) error {
+	collection := db.GetUserCollection()
+
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": userID, "deleted": true})
+	return err
+}
+
+// DeleteUserRecord removes the user document whether or not the user has
+// been deleted, to undo a registration that could not be completed.
+func DeleteUserRecord(ctx context.Context, userID primitive.ObjectID) error {
+	collection := db.GetUserCollection()
+
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
+
+// AnonymizeUser strips personal data from a deleted user while keeping the
+// document so that references to its id stay resolvable.
+func AnonymizeUser(ctx context.Context, userID primitive.ObjectID) error {
+	collection := db.GetUserCollection()
+
+	now := time.Now()
+	filter := bson.M{"_id": userID, "deleted": true}
+	update := bson.M{
+		"$set": bson.M{
+			"name":          "Deleted user",
+			"email":         "deleted-" + userID.Hex() + "@invalid.invalid",
+			"password":      "",
+			"anonymized_at": now,
+			"updated_at":    now,
+		},
+		"$unset": bson.M{
+			"password_history":         "",
+			"pending_email":            "",
+			"email_verified_at":        "",
+			"password_reset_forced_by": "",
+			"purge_after":              "",
+			"attributes":               "",
+			"suspension_reason":        "",
+		},
+	}
+
+	_, err := collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// exportBatchSize bounds how many users the export cursor holds at a time.
+const exportBatchSize = 500
+
+// StreamUsers calls fn for every user matching filter in ascending id order.
+// Users are decoded one at a time from the cursor, so memory use does not grow
+// with the number of users.
+func StreamUsers(ctx context.Context, filter *model.UserExportFilter, fn func(*model.User) error) error {
+	collection := db.GetUserCollection()
+
+	bsonFilter := bson.M{}
+	if filter.Name != "" {
+		bsonFilter["name"] = filter.Name
+	}
+	if filter.Email != "" {
+		bsonFilter["email"] = filter.Email
+	}
+	if filter.Role != "" {
+		bsonFilter["roles"] = filter.Role
+	}
+	if !filter.IncludeDeleted {
+		bsonFilter["deleted"] = false
+	}
+	created := bson.M{}
+	if !filter.CreatedAfter.IsZero() {
+		created["$gte"] = filter.CreatedAfter
+	}
+	if !filter.CreatedBefore.IsZero() {
+		created["$lt"] = filter.CreatedBefore
+	}
+	if len(created) > 0 {
+		bsonFilter["created_at"] = created
+	}
+	if !filter.After.IsZero() {
+		bsonFilter["_id"] = bson.M{"$gt": filter.After}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetBatchSize(exportBatchSize).
+		SetProjection(bson.M{"password": 0, "password_history": 0})
+
+	cursor, err := collection.Find(ctx, bsonFilter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var u model.User
+		if err := cursor.Decode(&u); err != nil {
+			return err
+		}
+		if err := fn(&u); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
 func ListUsers(ctx context.Context, filter *model.UserFilter) ([]*model.User, int64, error) {
 	collection := db.GetUserCollection()
 
@@ -63,6 +506,9 @@ func ListUsers(ctx context.Context, filter *model.UserFilter) ([]*model.User, in
 	if filter.Email != "" {
 		bsonFilter["email"] = filter.Email
 	}
+	for name, value := range filter.Attributes {
+		bsonFilter["attributes."+name] = value
+	}
 	bsonFilter["deleted"] = false
 
 	limit := filter.Limit
@@ -110,3 +556,41 @@ func CountUsers(ctx context.Context) (int64, error) {
 	count, err := collection.CountDocuments(ctx, filter)
 	return count, err
 }
+
+// AddUserRole adds role to the roles of an active user and reports whether
+// the user did not hold it yet. It returns mongo.ErrNoDocuments if there is no
+// such user.
+func AddUserRole(ctx context.Context, userID primitive.ObjectID, role string) (bool, error) {
+	collection := db.GetUserCollection()
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "deleted": false},
+		bson.M{"$addToSet": bson.M{"roles": role}},
+	)
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount == 0 {
+		return false, mongo.ErrNoDocuments
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// RemoveUserRole removes role from the roles of an active user and reports
+// whether the user held it. It returns mongo.ErrNoDocuments if there is no
+// such user.
+func RemoveUserRole(ctx context.Context, userID primitive.ObjectID, role string) (bool, error) {
+	collection := db.GetUserCollection()
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": userID, "deleted": false},
+		bson.M{"$pull": bson.M{"roles": role}},
+	)
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount == 0 {
+		return false, mongo.ErrNoDocuments
+	}
+	return result.ModifiedCount > 0, nil
+}
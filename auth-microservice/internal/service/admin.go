@@ -0,0 +1,433 @@
+package service
+
+import (
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"auth-microservice/internal/utils"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// validateRole reports a field error for field unless role exists.
+func (s *authService) validateRole(ctx context.Context, field, role string) error {
+	_, err := repository.GetRoleByName(ctx, role)
+	if err == mongo.ErrNoDocuments {
+		return newFieldError(field, "INVALID_ROLE", fmt.Sprintf("role %q does not exist", role))
+	}
+	return err
+}
+
+// validateRoles reports a field error for field unless roles is a non-empty
+// list of existing roles, and returns it without duplicates.
+func (s *authService) validateRoles(ctx context.Context, field string, roles []string) ([]string, error) {
+	result := []string{}
+	for _, r := range roles {
+		if !containsString(result, r) {
+			result = append(result, r)
+		}
+	}
+	if len(result) == 0 {
+		return nil, newFieldError(field, "REQUIRED", "at least one role is required")
+	}
+
+	existing, err := repository.GetRolesByName(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) == len(result) {
+		return result, nil
+	}
+
+	var violations []FieldViolation
+	for i, r := range result {
+		found := false
+		for _, e := range existing {
+			if e.Name == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			violations = append(violations, FieldViolation{
+				Field:       fmt.Sprintf("%s[%d]", field, i),
+				Reason:      "INVALID_ROLE",
+				Description: fmt.Sprintf("role %q does not exist", r),
+			})
+		}
+	}
+	return nil, &ValidationError{Violations: violations}
+}
+
+// requireRoleAssignment checks that the caller may give roles to a user.
+// Anything but the default role needs roles.assign, so that users.create
+// alone cannot be used to create admins.
+func (s *authService) requireRoleAssignment(ctx context.Context, userID primitive.ObjectID, roles []string) error {
+	for _, r := range roles {
+		if r != model.RoleUser {
+			return s.requirePermission(ctx, userID, model.PermissionRolesAssign)
+		}
+	}
+	return nil
+}
+
+// sameRoles reports whether a and b hold the same roles in any order.
+func sameRoles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, r := range a {
+		if !containsString(b, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ensureAdminRemains refuses to take roles.manage away from user (by changing
+// its roles to newRoles, or by deleting it when newRoles is nil) if it is the
+// last active user holding it. Without such a user roles could no longer be
+// managed.
+func (s *authService) ensureAdminRemains(ctx context.Context, user *model.User, newRoles []string) error {
+	managers, err := repository.RoleNamesWithPermission(ctx, model.PermissionRolesManage)
+	if err != nil {
+		return err
+	}
+	if !containsAny(managers, user.Roles) || containsAny(managers, newRoles) {
+		return nil
+	}
+
+	holders, err := repository.CountUsersWithRoles(ctx, managers)
+	if err != nil {
+		return err
+	}
+	if holders <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(values, candidates []string) bool {
+	for _, c := range candidates {
+		if containsString(values, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether any of the user's roles grants permission.
+// Roles that no longer exist grant nothing.
+func (s *authService) HasPermission(ctx context.Context, userID primitive.ObjectID, permission string) (bool, error) {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if len(user.Roles) == 0 {
+		return false, nil
+	}
+
+	roles, err := repository.GetRolesByName(ctx, user.Roles)
+	if err != nil {
+		return false, err
+	}
+	return model.RolesGrant(roles, permission), nil
+}
+
+// userRoles returns the roles of user that still exist.
+func (s *authService) userRoles(ctx context.Context, user *model.User) ([]model.Role, error) {
+	if len(user.Roles) == 0 {
+		return nil, nil
+	}
+	return repository.GetRolesByName(ctx, user.Roles)
+}
+
+// requireCredentialAccess refuses changes to the email address or password
+// of target unless the caller holds every permission target has. Otherwise
+// users.update alone would be enough to take over an admin account by
+// pointing its email at an address the caller controls.
+func (s *authService) requireCredentialAccess(ctx context.Context, userID primitive.ObjectID, target *model.User) error {
+	callerRoles, err := s.callerRoles(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.requireRolesCover(ctx, callerRoles, target)
+}
+
+// callerRoles returns the roles of the user with userID.
+func (s *authService) callerRoles(ctx context.Context, userID primitive.ObjectID) ([]model.Role, error) {
+	caller, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.userRoles(ctx, caller)
+}
+
+// requireRolesCover fails with ErrForbidden unless callerRoles grant every
+// permission target has.
+func (s *authService) requireRolesCover(ctx context.Context, callerRoles []model.Role, target *model.User) error {
+	targetRoles, err := s.userRoles(ctx, target)
+	if err != nil {
+		return err
+	}
+	if !model.RolesCover(callerRoles, targetRoles) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (s *authService) requirePermission(ctx context.Context, userID primitive.ObjectID, permission string) error {
+	allowed, err := s.HasPermission(ctx, userID, permission)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (s *authService) recordAdminAction(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, action string, details map[string]interface{}) {
+	err := repository.RecordAudit(ctx, &model.AuditEntry{
+		ActorID:      adminUserID,
+		Action:       action,
+		TargetUserID: targetUserID,
+		Details:      details,
+	})
+	if err != nil {
+		log.Printf("audit %s for %s failed: %v", action, targetUserID.Hex(), err)
+	}
+}
+
+// AdminCreateUser creates an account on behalf of a user. The same password
+// policy as Register applies. Unless the admin marks the address as
+// verified, a verification email is sent.
+func (s *authService) AdminCreateUser(ctx context.Context, adminUserID primitive.ObjectID, user *model.User) error {
+	if len(user.Roles) == 0 {
+		user.Roles = []string{model.RoleUser}
+	}
+	roles, err := s.validateRoles(ctx, "roles", user.Roles)
+	if err != nil {
+		return err
+	}
+	user.Roles = roles
+	if err := s.requireRoleAssignment(ctx, adminUserID, user.Roles); err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		user.EmailVerifiedAt = time.Now()
+	}
+
+	attributes, err := s.newUserAttributes(ctx, user.Attributes, true)
+	if err != nil {
+		return err
+	}
+	user.Attributes = attributes
+
+	if err := s.createUser(ctx, user); err != nil {
+		return err
+	}
+
+	if !user.EmailVerified {
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("verification email for %s failed: %v", user.ID.Hex(), err)
+		}
+	}
+
+	s.recordAdminAction(ctx, adminUserID, user.ID, model.AuditActionCreateUser, map[string]interface{}{"roles": user.Roles})
+	return nil
+}
+
+// AdminUpdateUser changes the given fields of a user. An email changed by an
+// admin takes effect right away, without the confirmation link used by
+// UpdateProfile; it is unverified unless EmailVerified is set as well.
+func (s *authService) AdminUpdateUser(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, update *model.AdminUserUpdate) (*model.User, error) {
+	user, err := s.GetUserByID(ctx, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{}
+	var unset []string
+	// Only the names of changed fields are audited. Their values are
+	// personal data that would outlive the account in the audit log.
+	var changed []string
+	emailChanged := false
+
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return nil, newFieldError("name", "REQUIRED", "name must not be empty")
+		}
+		set["name"] = name
+		changed = append(changed, "name")
+	}
+
+	if update.Email != nil {
+		email, err := utils.NormalizeEmail(*update.Email)
+		if err != nil {
+			return nil, newFieldError("email", "INVALID_EMAIL", "email address is not valid")
+		}
+		if email != user.Email {
+			if err := s.requireCredentialAccess(ctx, adminUserID, user); err != nil {
+				return nil, err
+			}
+			taken, err := repository.IsEmailTaken(ctx, email, targetUserID)
+			if err != nil {
+				return nil, err
+			}
+			if taken {
+				return nil, ErrUserExists
+			}
+			set["email"] = email
+			unset = append(unset, "pending_email")
+			changed = append(changed, "email")
+			emailChanged = true
+		}
+	}
+
+	if update.Roles != nil {
+		roles, err := s.validateRoles(ctx, "roles", *update.Roles)
+		if err != nil {
+			return nil, err
+		}
+		if !sameRoles(roles, user.Roles) {
+			if err := s.requirePermission(ctx, adminUserID, model.PermissionRolesAssign); err != nil {
+				return nil, err
+			}
+			if err := s.ensureAdminRemains(ctx, user, roles); err != nil {
+				return nil, err
+			}
+			set["roles"] = roles
+			changed = append(changed, "roles")
+		}
+	}
+
+	// A new address starts out unverified unless the admin vouches for it.
+	verified := user.EmailVerified && !emailChanged
+	if update.EmailVerified != nil {
+		verified = *update.EmailVerified
+	}
+	if verified != user.EmailVerified || emailChanged {
+		if err := s.requireCredentialAccess(ctx, adminUserID, user); err != nil {
+			return nil, err
+		}
+		set["email_verified"] = verified
+		if verified {
+			set["email_verified_at"] = time.Now()
+		} else {
+			unset = append(unset, "email_verified_at")
+		}
+		changed = append(changed, "email_verified")
+	}
+
+	if update.MustChangePassword != nil && *update.MustChangePassword != user.MustChangePassword {
+		if err := s.requireCredentialAccess(ctx, adminUserID, user); err != nil {
+			return nil, err
+		}
+		set["must_change_password"] = *update.MustChangePassword
+		changed = append(changed, "must_change_password")
+	}
+
+	if len(update.Attributes) > 0 {
+		merged, err := s.mergeAttributes(ctx, user.Attributes, update.Attributes, true)
+		if err != nil {
+			return nil, err
+		}
+		set["attributes"] = merged
+		changed = append(changed, "attributes")
+	}
+
+	if len(changed) == 0 {
+		return user, nil
+	}
+
+	err = repository.UpdateUserFields(ctx, targetUserID, set, unset)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrUserExists
+	}
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if roles, ok := set["roles"]; ok {
+		s.recordAdminAction(ctx, adminUserID, targetUserID, model.AuditActionRoleChange, map[string]interface{}{"from": user.Roles, "to": roles})
+	}
+	s.recordAdminAction(ctx, adminUserID, targetUserID, model.AuditActionUpdateUser, map[string]interface{}{"fields": changed})
+
+	updated, err := s.GetUserByID(ctx, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if emailChanged && !updated.EmailVerified {
+		if err := s.sendVerificationEmail(ctx, updated); err != nil {
+			log.Printf("verification email for %s failed: %v", updated.ID.Hex(), err)
+		}
+	}
+	return updated, nil
+}
+
+// AdminDeleteUser deletes an account the same way DeleteProfile does, so it
+// can still be restored during the grace period.
+func (s *authService) AdminDeleteUser(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) (*model.User, error) {
+	user, err := s.GetUserByID(ctx, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureAdminRemains(ctx, user, nil); err != nil {
+		return nil, err
+	}
+
+	if err := repository.MarkUserDeleted(ctx, targetUserID, time.Now().Add(s.deletionGracePeriod)); err != nil {
+		return nil, err
+	}
+	if err := repository.RevokeUserSessions(ctx, targetUserID, primitive.NilObjectID); err != nil {
+		return nil, err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, targetUserID, model.AuditActionDeleteUser, nil)
+
+	deleted, err := repository.GetUserByIDAnyState(ctx, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// AdminRestoreUser undoes a deletion while the account is inside its grace
+// period, without the password RestoreAccount asks for.
+func (s *authService) AdminRestoreUser(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) (*model.User, error) {
+	err := repository.RestoreUser(ctx, targetUserID)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrUserExists
+	}
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, targetUserID, model.AuditActionRestoreUser, nil)
+
+	return s.GetUserByID(ctx, targetUserID)
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var attributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// DefineAttribute creates or replaces an attribute definition. Values already
+// stored for the attribute are checked against the new definition the next
+// time they are written.
+func (s *authService) DefineAttribute(ctx context.Context, adminUserID primitive.ObjectID, def *model.AttributeDefinition) error {
+	if !attributeNamePattern.MatchString(def.Name) {
+		return newFieldError("name", "INVALID_NAME", "name must start with a lowercase letter and contain only lowercase letters, digits and underscores (at most 64 characters)")
+	}
+	switch def.Type {
+	case model.AttributeTypeString, model.AttributeTypeNumber, model.AttributeTypeBool:
+	default:
+		return newFieldError("type", "INVALID_TYPE", "type must be string, number or bool")
+	}
+	if def.Pattern != "" {
+		if def.Type != model.AttributeTypeString {
+			return newFieldError("pattern", "PATTERN_NOT_ALLOWED", "pattern only applies to string attributes")
+		}
+		if _, err := compileAttributePattern(def.Pattern); err != nil {
+			return newFieldError("pattern", "INVALID_PATTERN", "pattern is not a valid regular expression: "+err.Error())
+		}
+	}
+
+	if err := repository.UpsertAttributeDefinition(ctx, def); err != nil {
+		return err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, primitive.NilObjectID, model.AuditActionDefineAttribute, map[string]interface{}{
+		"name":          def.Name,
+		"type":          def.Type,
+		"required":      def.Required,
+		"pattern":       def.Pattern,
+		"user_editable": def.UserEditable,
+	})
+	return nil
+}
+
+func (s *authService) ListAttributeDefinitions(ctx context.Context) ([]model.AttributeDefinition, error) {
+	return repository.ListAttributeDefinitions(ctx)
+}
+
+// DeleteAttributeDefinition removes the definition together with the
+// attribute's value on every user.
+func (s *authService) DeleteAttributeDefinition(ctx context.Context, adminUserID primitive.ObjectID, name string) error {
+	err := repository.DeleteAttributeDefinition(ctx, name)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, primitive.NilObjectID, model.AuditActionDeleteAttribute, map[string]interface{}{"name": name})
+	return nil
+}
+
+// compileAttributePattern anchors the pattern so it has to match the whole
+// value.
+func compileAttributePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+func (s *authService) attributeSchema(ctx context.Context) (map[string]model.AttributeDefinition, error) {
+	defs, err := repository.ListAttributeDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	schema := make(map[string]model.AttributeDefinition, len(defs))
+	for _, def := range defs {
+		schema[def.Name] = def
+	}
+	return schema, nil
+}
+
+// userEditableAttributes returns the attributes users may set themselves,
+// leaving out those only admins can set and those no longer defined.
+func userEditableAttributes(schema map[string]model.AttributeDefinition, attributes map[string]interface{}) map[string]interface{} {
+	visible := make(map[string]interface{}, len(attributes))
+	for name, value := range attributes {
+		if def, ok := schema[name]; ok && def.UserEditable {
+			visible[name] = value
+		}
+	}
+	return visible
+}
+
+// newUserAttributes validates the attributes of a user being created. Every
+// required attribute must be set, except that users (byAdmin false) are only
+// held to required attributes they are allowed to set.
+func (s *authService) newUserAttributes(ctx context.Context, attributes map[string]interface{}, byAdmin bool) (map[string]interface{}, error) {
+	return s.applyAttributes(ctx, nil, attributes, byAdmin, true)
+}
+
+// mergeAttributes applies changes to the attributes of an existing user. A nil
+// value in changes removes the attribute. Users (byAdmin false) may only
+// change attributes marked UserEditable. Only the attributes in changes are
+// checked, so a required attribute may not be removed, but one that was
+// defined after the user was created does not block unrelated changes.
+func (s *authService) mergeAttributes(ctx context.Context, current, changes map[string]interface{}, byAdmin bool) (map[string]interface{}, error) {
+	return s.applyAttributes(ctx, current, changes, byAdmin, false)
+}
+
+func (s *authService) applyAttributes(ctx context.Context, current, changes map[string]interface{}, byAdmin, create bool) (map[string]interface{}, error) {
+	schema, err := s.attributeSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]interface{}, len(current)+len(changes))
+	for name, value := range current {
+		merged[name] = value
+	}
+
+	validation := &ValidationError{}
+	violate := func(name, reason, description string) {
+		validation.Violations = append(validation.Violations, FieldViolation{
+			Field:       "attributes." + name,
+			Reason:      reason,
+			Description: description,
+		})
+	}
+
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		def, ok := schema[name]
+		if !ok {
+			violate(name, "UNKNOWN_ATTRIBUTE", "attribute is not defined")
+			continue
+		}
+		if !byAdmin && !def.UserEditable {
+			violate(name, "READ_ONLY", "attribute can only be changed by an admin")
+			continue
+		}
+
+		value := changes[name]
+		if value == nil {
+			delete(merged, name)
+			continue
+		}
+		normalized, reason, description := checkAttributeValue(def, value)
+		if reason != "" {
+			violate(name, reason, description)
+			continue
+		}
+		merged[name] = normalized
+	}
+
+	for _, def := range schema {
+		if !def.Required || (!byAdmin && !def.UserEditable) {
+			continue
+		}
+		if _, changed := changes[def.Name]; !create && !changed {
+			continue
+		}
+		if _, ok := merged[def.Name]; !ok {
+			violate(def.Name, "REQUIRED", "attribute is required")
+		}
+	}
+
+	if len(validation.Violations) > 0 {
+		return nil, validation
+	}
+	return merged, nil
+}
+
+// checkAttributeValue returns the value in its stored form, or the reason and
+// description of why it does not fit the definition.
+func checkAttributeValue(def model.AttributeDefinition, value interface{}) (interface{}, string, string) {
+	switch def.Type {
+	case model.AttributeTypeString:
+		str, ok := value.(string)
+		if !ok {
+			return nil, "TYPE_MISMATCH", "attribute must be a string"
+		}
+		if def.Pattern != "" {
+			re, err := compileAttributePattern(def.Pattern)
+			if err != nil || !re.MatchString(str) {
+				return nil, "PATTERN_MISMATCH", fmt.Sprintf("attribute must match %s", def.Pattern)
+			}
+		}
+		return str, "", ""
+
+	case model.AttributeTypeNumber:
+		switch n := value.(type) {
+		case float64:
+			return n, "", ""
+		case int:
+			return float64(n), "", ""
+		case int32:
+			return float64(n), "", ""
+		case int64:
+			return float64(n), "", ""
+		}
+		return nil, "TYPE_MISMATCH", "attribute must be a number"
+
+	case model.AttributeTypeBool:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, "TYPE_MISMATCH", "attribute must be a boolean"
+		}
+		return b, "", ""
+	}
+	return nil, "UNKNOWN_ATTRIBUTE", "attribute has an unsupported type"
+}
+
+// attributeFilter converts ListUsers attribute filters, which may arrive as
+// strings, to the types they are stored with.
+func (s *authService) attributeFilter(ctx context.Context, filter map[string]interface{}) (map[string]interface{}, error) {
+	if len(filter) == 0 {
+		return nil, nil
+	}
+
+	schema, err := s.attributeSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	typed := make(map[string]interface{}, len(filter))
+	for name, value := range filter {
+		def, ok := schema[name]
+		if !ok {
+			return nil, newFieldError("attributes."+name, "UNKNOWN_ATTRIBUTE", "attribute is not defined")
+		}
+
+		str, isString := value.(string)
+		switch {
+		case !isString || def.Type == model.AttributeTypeString:
+			typed[name] = value
+		case def.Type == model.AttributeTypeNumber:
+			n, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+			if err != nil {
+				return nil, newFieldError("attributes."+name, "TYPE_MISMATCH", "attribute must be a number")
+			}
+			typed[name] = n
+		case def.Type == model.AttributeTypeBool:
+			b, err := strconv.ParseBool(strings.TrimSpace(str))
+			if err != nil {
+				return nil, newFieldError("attributes."+name, "TYPE_MISMATCH", "attribute must be a boolean")
+			}
+			typed[name] = b
+		}
+	}
+	return typed, nil
+}
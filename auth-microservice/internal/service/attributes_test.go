@@ -0,0 +1,40 @@
+package service
+
+import (
+	"auth-microservice/internal/model"
+	"reflect"
+	"testing"
+)
+
+func TestUserEditableAttributes(t *testing.T) {
+	schema := map[string]model.AttributeDefinition{
+		"nickname":    {Name: "nickname", Type: model.AttributeTypeString, UserEditable: true},
+		"employee_id": {Name: "employee_id", Type: model.AttributeTypeString},
+		"newsletter":  {Name: "newsletter", Type: model.AttributeTypeBool, UserEditable: true},
+	}
+
+	tests := []struct {
+		name       string
+		attributes map[string]interface{}
+		want       map[string]interface{}
+	}{
+		{"none", nil, map[string]interface{}{}},
+		{
+			"admin only attributes left out",
+			map[string]interface{}{"nickname": "jo", "employee_id": "E123456", "newsletter": true},
+			map[string]interface{}{"nickname": "jo", "newsletter": true},
+		},
+		{
+			"undefined attributes left out",
+			map[string]interface{}{"nickname": "jo", "legacy_flag": true},
+			map[string]interface{}{"nickname": "jo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userEditableAttributes(schema, tt.attributes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("userEditableAttributes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,16 +1,25 @@
 package service
 
 import (
+	"auth-microservice/internal/breach"
+	"auth-microservice/internal/config"
 	"auth-microservice/internal/db"
+	"auth-microservice/internal/mailer"
 	"auth-microservice/internal/model"
+	"auth-microservice/internal/passwordpolicy"
 	"auth-microservice/internal/repository"
 	"auth-microservice/internal/utils"
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"net/url"
+	"strings"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var (
@@ -20,88 +29,452 @@ var (
 	ErrUnauthenticated    = errors.New("unauthenticated")
 	ErrNotFound           = errors.New("not found")
 	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrPasswordReused     = errors.New("password was used recently, choose a different one")
+	ErrEmailNotVerified   = errors.New("email address has not been verified")
+	ErrAccountSuspended   = errors.New("account is suspended")
+	ErrAccountLocked      = errors.New("account is locked after too many failed login attempts")
+	ErrLastAdmin          = errors.New("the last user who can manage roles cannot lose that permission")
+	ErrRoleInUse          = errors.New("role is still assigned to users")
 )
 
+// AuthService implements the RPCs of the auth service. The permissions a
+// method always needs are declared with the (authz.policy) option and checked
+// by the interceptor before the handler runs, so methods do not check them
+// again. Methods only check permissions that depend on the request, such as
+// roles.assign for giving out roles other than the default one.
 type AuthService interface {
-	Register(ctx context.Context, user *model.User) error
-	Login(ctx context.Context, email, password string) (string, error)
+	Register(ctx context.Context, user *model.User, accepted []model.PolicyVersion) error
+	Login(ctx context.Context, email, password string) (*LoginResult, error)
 	GetUserByID(ctx context.Context, id primitive.ObjectID) (*model.User, error)
+	ViewUser(ctx context.Context, callerID, id primitive.ObjectID) (*model.User, error)
 	Logout(ctx context.Context, token string) error
-	IsAdmin(ctx context.Context, userID primitive.ObjectID) (bool, error)
+	HasPermission(ctx context.Context, userID primitive.ObjectID, permission string) (bool, error)
 	AddRole(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, newRole string) error
+	RemoveRole(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, role string) error
+	UpdateUserRoles(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, roles []string) (*model.User, error)
 	ListUsers(ctx context.Context, filter *model.UserFilter) ([]*model.User, int64, error)
-	UpdateProfile(ctx context.Context, userID primitive.ObjectID, newName, newEmail string) error
+	UpdateProfile(ctx context.Context, userID primitive.ObjectID, newName, newEmail string, attributes map[string]interface{}) (bool, error)
 	DeleteProfile(ctx context.Context, userID primitive.ObjectID) error
-	GeneratePasswordResetToken(ctx context.Context, userID primitive.ObjectID) (string, error)
+	GeneratePasswordResetToken(ctx context.Context, userID primitive.ObjectID) error
+	RequestPasswordReset(ctx context.Context, email string) error
 	ResetPassword(ctx context.Context, resetToken, newPassword string) error
+	ChangePassword(ctx context.Context, userID, sessionID primitive.ObjectID, currentPassword, newPassword string) error
+	ForcePasswordReset(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	RevertEmailChange(ctx context.Context, token string) error
+	RestoreAccount(ctx context.Context, email, password string) error
+	ListPendingDeletions(ctx context.Context, page, limit int64) ([]*model.User, int64, error)
+	ExpediteDeletion(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) error
+	PurgeExpiredAccounts(ctx context.Context) (int, error)
+	ExportMyData(ctx context.Context, userID primitive.ObjectID) (*model.UserDataExport, error)
+	AdminExportUserData(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) (*model.UserDataExport, error)
+	SuspendUser(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, reason string, until time.Time) error
+	UnsuspendUser(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) error
+	AdminCreateUser(ctx context.Context, adminUserID primitive.ObjectID, user *model.User) error
+	AdminUpdateUser(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, update *model.AdminUserUpdate) (*model.User, error)
+	AdminDeleteUser(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) (*model.User, error)
+	AdminRestoreUser(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) (*model.User, error)
+	InviteUser(ctx context.Context, adminUserID primitive.ObjectID, email, role string) (*model.Invitation, error)
+	AcceptInvitation(ctx context.Context, token, name, password string, attributes map[string]interface{}) (*model.User, error)
+	ListInvitations(ctx context.Context, status string, page, limit int64) ([]*model.Invitation, int64, error)
+	RevokeInvitation(ctx context.Context, adminUserID, invitationID primitive.ObjectID) error
+	ImportUsers(ctx context.Context, adminUserID primitive.ObjectID, opts model.ImportOptions,
+		next func() (*model.ImportRecord, error), report func(*model.ImportResult) error) error
+	ExportUsers(ctx context.Context, adminUserID primitive.ObjectID, filter *model.UserExportFilter, checkpoint string, fn func(*model.User) error) error
+	DefineAttribute(ctx context.Context, adminUserID primitive.ObjectID, def *model.AttributeDefinition) error
+	ListAttributeDefinitions(ctx context.Context) ([]model.AttributeDefinition, error)
+	DeleteAttributeDefinition(ctx context.Context, adminUserID primitive.ObjectID, name string) error
+	RequestAccountUnlock(ctx context.Context, email string) error
+	ConfirmAccountUnlock(ctx context.Context, token string) error
+	UnlockAccount(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) error
+	ListLockedAccounts(ctx context.Context, page, limit int64) ([]*model.User, int64, error)
+	PublishPolicy(ctx context.Context, adminUserID primitive.ObjectID, policy *model.PolicyDocument) error
+	CurrentPolicies(ctx context.Context) ([]model.PolicyDocument, error)
+	ListPolicyVersions(ctx context.Context, policyType string) ([]model.PolicyDocument, error)
+	AcceptPolicies(ctx context.Context, userID primitive.ObjectID, accepted []model.PolicyVersion) error
+	PendingPolicies(ctx context.Context, userID primitive.ObjectID) ([]model.PolicyDocument, error)
+	ListPolicyAcceptances(ctx context.Context, targetUserID primitive.ObjectID) ([]model.PolicyAcceptance, error)
+	CreateRole(ctx context.Context, adminUserID primitive.ObjectID, role *model.Role) error
+	UpdateRole(ctx context.Context, adminUserID primitive.ObjectID, name, description string, permissions []string) (*model.Role, error)
+	DeleteRole(ctx context.Context, adminUserID primitive.ObjectID, name string) error
+	ListRoles(ctx context.Context) ([]model.Role, error)
 }
 
-type authService struct{}
+// Email verification policies, selected with EMAIL_VERIFICATION_POLICY.
+const (
+	// EmailVerificationBlock refuses to log in unverified accounts.
+	EmailVerificationBlock = "block"
+	// EmailVerificationRestrict logs them in with a restricted token.
+	EmailVerificationRestrict = "restrict"
+	// EmailVerificationOff only sends the verification email.
+	EmailVerificationOff = "off"
+)
 
-func NewAuthService() AuthService {
-	return &authService{}
+// Options holds the collaborators the service needs. BreachChecker may be nil
+// to disable breached-password screening.
+type Options struct {
+	Mailer         mailer.Mailer
+	Hasher         utils.PasswordHasher
+	BreachChecker  breach.Checker
+	PasswordPolicy *passwordpolicy.Policy
 }
 
-func (s *authService) Register(ctx context.Context, user *model.User) error {
-	if user.Email == "" || user.Password == "" || user.Name == "" {
-		return ErrInvalidArgument
+type LoginResult struct {
+	Token string
+	// PasswordChangeRequired means Token is restricted to ChangePassword.
+	PasswordChangeRequired bool
+	// EmailVerificationRequired means Token is restricted until the email
+	// address is verified.
+	EmailVerificationRequired bool
+}
+
+type authService struct {
+	mailer           mailer.Mailer
+	hasher           utils.PasswordHasher
+	breachChecker    breach.Checker
+	passwordPolicy   *passwordpolicy.Policy
+	passwordResetURL string
+	passwordResetTTL time.Duration
+
+	emailVerificationPolicy string
+	emailVerificationURL    string
+	emailVerificationTTL    time.Duration
+
+	emailChangeURL       string
+	emailChangeTTL       time.Duration
+	emailChangeRevertURL string
+	emailChangeRevertTTL time.Duration
+
+	deletionGracePeriod time.Duration
+	purgeMode           string
+
+	invitationURL string
+	invitationTTL time.Duration
+
+	lockoutThreshold          int
+	lockoutBaseDuration       time.Duration
+	lockoutMaxDuration        time.Duration
+	lockoutPermanentThreshold int
+	lockoutSourceFailures     int
+	lockoutSourceWindow       time.Duration
+	accountUnlockURL          string
+	accountUnlockTTL          time.Duration
+}
+
+func NewAuthService(opts Options) AuthService {
+	return &authService{
+		mailer:           opts.Mailer,
+		hasher:           opts.Hasher,
+		breachChecker:    opts.BreachChecker,
+		passwordPolicy:   opts.PasswordPolicy,
+		passwordResetURL: config.String("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+		passwordResetTTL: config.Duration("PASSWORD_RESET_TOKEN_TTL", time.Hour),
+
+		emailVerificationPolicy: config.String("EMAIL_VERIFICATION_POLICY", EmailVerificationRestrict),
+		emailVerificationURL:    config.String("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email"),
+		emailVerificationTTL:    config.Duration("EMAIL_VERIFICATION_TOKEN_TTL", 24*time.Hour),
+
+		emailChangeURL:       config.String("EMAIL_CHANGE_URL", "http://localhost:3000/confirm-email"),
+		emailChangeTTL:       config.Duration("EMAIL_CHANGE_TOKEN_TTL", 24*time.Hour),
+		emailChangeRevertURL: config.String("EMAIL_CHANGE_REVERT_URL", "http://localhost:3000/revert-email"),
+		emailChangeRevertTTL: config.Duration("EMAIL_CHANGE_REVERT_TOKEN_TTL", 7*24*time.Hour),
+
+		deletionGracePeriod: config.Duration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour),
+		purgeMode:           config.String("ACCOUNT_PURGE_MODE", PurgeModeAnonymize),
+
+		invitationURL: config.String("INVITATION_URL", "http://localhost:3000/accept-invitation"),
+		invitationTTL: config.Duration("INVITATION_TOKEN_TTL", 7*24*time.Hour),
+
+		lockoutThreshold:          config.Int("LOCKOUT_THRESHOLD", 5),
+		lockoutBaseDuration:       config.Duration("LOCKOUT_BASE_DURATION", time.Minute),
+		lockoutMaxDuration:        config.Duration("LOCKOUT_MAX_DURATION", 24*time.Hour),
+		lockoutPermanentThreshold: config.Int("LOCKOUT_PERMANENT_THRESHOLD", 20),
+		lockoutSourceFailures:     config.Int("LOCKOUT_SOURCE_FAILURES", 10),
+		lockoutSourceWindow:       config.Duration("LOCKOUT_SOURCE_WINDOW", 24*time.Hour),
+		accountUnlockURL:          config.String("ACCOUNT_UNLOCK_URL", "http://localhost:3000/unlock-account"),
+		accountUnlockTTL:          config.Duration("ACCOUNT_UNLOCK_TOKEN_TTL", time.Hour),
+	}
+}
+
+// Register creates an account. accepted must name the current version of
+// every policy that has a mandatory version.
+func (s *authService) Register(ctx context.Context, user *model.User, accepted []model.PolicyVersion) error {
+	policies, err := s.resolveAcceptedPolicies(ctx, accepted, true)
+	if err != nil {
+		return err
 	}
 
-	if !utils.ValidEmail(user.Email) {
+	attributes, err := s.newUserAttributes(ctx, user.Attributes, false)
+	if err != nil {
+		return err
+	}
+	user.Attributes = attributes
+
+	user.EmailVerified = false
+	if err := s.createUser(ctx, user); err != nil {
+		return err
+	}
+
+	if err := s.recordPolicyAcceptances(ctx, user.ID, policies); err != nil {
+		// An account without its acceptances would hold on to the email
+		// address, so the user could neither use it nor register again.
+		s.undoRegistration(user.ID)
+		return err
+	}
+
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("verification email for %s failed: %v", user.ID.Hex(), err)
+	}
+	return nil
+}
+
+// undoRegistration removes a user created by Register together with any
+// acceptances already recorded. It runs even if the request was cancelled.
+func (s *authService) undoRegistration(userID primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := repository.DeleteUserPolicyAcceptances(ctx, userID); err != nil {
+		log.Printf("undoing registration of %s: deleting policy acceptances failed: %v", userID.Hex(), err)
+	}
+	if err := repository.DeleteUserRecord(ctx, userID); err != nil {
+		log.Printf("undoing registration of %s: deleting user failed: %v", userID.Hex(), err)
+	}
+}
+
+// createUser validates and stores a new user, replacing the plain text
+// password with its hash.
+func (s *authService) createUser(ctx context.Context, user *model.User) error {
+	if user.Email == "" || user.Password == "" || user.Name == "" {
 		return ErrInvalidArgument
 	}
 
-	if !utils.ValidPassword(user.Password) {
+	email, err := utils.NormalizeEmail(user.Email)
+	if err != nil {
 		return ErrInvalidArgument
 	}
+	user.Email = email
 
-	existing, err := repository.GetUserByEmail(user.Email)
-	if err == nil && existing != nil {
-		return ErrUserExists
+	if err := s.validateNewPassword(ctx, user, user.Password); err != nil {
+		return err
 	}
 
-	hashedPassword, err := utils.HashPassword(user.Password)
+	hashedPassword, err := s.hasher.Hash(user.Password)
 	if err != nil {
 		return err
 	}
 
 	user.Password = hashedPassword
-	user.ID = primitive.NewObjectID()
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
 	user.Deleted = false
 	user.CreatedAt = time.Now()
+	user.PasswordChangedAt = user.CreatedAt
 
-	return repository.CreateUser(user)
+	// The unique email index decides concurrent registrations for the same
+	// address.
+	err = repository.CreateUser(user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrUserExists
+	}
+	return err
 }
 
-func (s *authService) Login(ctx context.Context, email, password string) (string, error) {
+func (s *authService) Login(ctx context.Context, email, password string) (*LoginResult, error) {
 	if email == "" || password == "" {
-		return "", ErrInvalidArgument
+		return nil, ErrInvalidArgument
+	}
+
+	email, err := utils.NormalizeEmail(email)
+	if err != nil {
+		return nil, ErrInvalidCredentials
 	}
 
 	user, err := repository.GetUserByEmail(email)
 	if err != nil || user == nil {
-		return "", ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
 	}
 
 	key := fmt.Sprintf("login_attempts:%s", email)
 	allowed, err := utils.RateLimit(ctx, key, 5, time.Minute)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if !allowed {
-		return "", ErrForbidden
+		s.recordLogin(ctx, user.ID, false, "rate_limited")
+		return nil, ErrForbidden
+	}
+
+	// Only callers who know the password learn that the account is locked;
+	// to everyone else a locked account looks like a wrong password. Guesses
+	// made while it is locked are not counted.
+	match, needsRehash := s.hasher.Verify(password, user.Password)
+	if isLocked(user, time.Now()) {
+		s.recordLogin(ctx, user.ID, false, "locked")
+		if !match {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, ErrAccountLocked
+	}
+	if !match {
+		s.recordLogin(ctx, user.ID, false, "invalid_password")
+		s.registerFailedLogin(ctx, user)
+		return nil, ErrInvalidCredentials
+	}
+	s.clearFailedLogins(ctx, user)
+	if user.IsSuspended(time.Now()) {
+		s.recordLogin(ctx, user.ID, false, "suspended")
+		return nil, ErrAccountSuspended
+	}
+	if needsRehash {
+		s.rehashPassword(ctx, user, password)
 	}
 
-	if !utils.CheckPasswordHash(password, user.Password) {
-		return "", ErrInvalidCredentials
+	verificationRequired := !user.EmailVerified && s.emailVerificationPolicy != EmailVerificationOff
+	if verificationRequired && s.emailVerificationPolicy == EmailVerificationBlock {
+		s.recordLogin(ctx, user.ID, false, "email_not_verified")
+		return nil, ErrEmailNotVerified
 	}
 
-	token, err := utils.GenerateJWT(user.ID.Hex())
+	scope, ttl := "", utils.AccessTokenTTL
+	changeRequired := s.passwordChangeRequired(user)
+	switch {
+	case changeRequired:
+		scope, ttl = utils.ScopePasswordChange, utils.RestrictedTokenTTL
+	case verificationRequired:
+		scope = utils.ScopeUnverifiedEmail
+	}
+
+	token, err := s.createSession(ctx, user.ID, scope, ttl)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLogin(ctx, user.ID, true, "")
+
+	return &LoginResult{
+		Token:                     token,
+		PasswordChangeRequired:    changeRequired,
+		EmailVerificationRequired: verificationRequired,
+	}, nil
+}
+
+// recordLogin appends a login attempt to the user's history. Failures are
+// logged rather than returned so that history never blocks a login.
+func (s *authService) recordLogin(ctx context.Context, userID primitive.ObjectID, success bool, reason string) {
+	ip, userAgent := utils.ClientInfo(ctx)
+	err := repository.RecordLogin(ctx, &model.LoginEvent{
+		UserID:    userID,
+		Success:   success,
+		Reason:    reason,
+		IP:        ip,
+		UserAgent: userAgent,
+	})
 	if err != nil {
+		log.Printf("recording login for %s failed: %v", userID.Hex(), err)
+	}
+}
+
+func (s *authService) createSession(ctx context.Context, userID primitive.ObjectID, scope string, ttl time.Duration) (string, error) {
+	session := &model.Session{
+		UserID:    userID,
+		Scope:     scope,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := repository.CreateSession(ctx, session); err != nil {
 		return "", err
 	}
 
-	return token, nil
+	return utils.GenerateJWT(userID.Hex(), session.ID.Hex(), scope, session.ExpiresAt)
+}
+
+// passwordChangeRequired reports whether the user was flagged for a password
+// change or their password is older than the maximum age configured for one
+// of their roles through PASSWORD_MAX_AGE_<ROLE> (e.g.
+// PASSWORD_MAX_AGE_ADMIN=2160h). The shortest configured age wins.
+func (s *authService) passwordChangeRequired(user *model.User) bool {
+	if user.MustChangePassword {
+		return true
+	}
+
+	var maxAge time.Duration
+	for _, role := range user.Roles {
+		age := config.Duration("PASSWORD_MAX_AGE_"+strings.ToUpper(role), 0)
+		if age > 0 && (maxAge == 0 || age < maxAge) {
+			maxAge = age
+		}
+	}
+	if maxAge <= 0 {
+		return false
+	}
+
+	changedAt := user.PasswordChangedAt
+	if changedAt.IsZero() {
+		changedAt = user.CreatedAt
+	}
+	return time.Since(changedAt) > maxAge
+}
+
+// validateNewPassword runs the checks shared by every path that sets a
+// password for user.
+func (s *authService) validateNewPassword(ctx context.Context, user *model.User, password string) error {
+	violations := s.passwordPolicy.Validate(password, user.Name, user.Email)
+	if len(violations) > 0 {
+		err := &ValidationError{}
+		for _, v := range violations {
+			err.Violations = append(err.Violations, FieldViolation{
+				Field:       "password",
+				Reason:      strings.ToUpper(v.Rule),
+				Description: "password " + v.Message,
+			})
+		}
+		return err
+	}
+
+	if s.breachChecker != nil {
+		count, err := s.breachChecker.Count(ctx, password)
+		if err != nil {
+			// Screening is best effort; an unavailable corpus must not block sign-ups.
+			log.Printf("breached password check failed: %v", err)
+		} else if count > 0 {
+			return newFieldError("password", "BREACHED_PASSWORD", "password has appeared in a data breach, choose a different one")
+		}
+	}
+
+	return nil
+}
+
+// rehashPassword upgrades a hash produced with an outdated algorithm or
+// parameters. Failures are logged and do not affect the login.
+func (s *authService) rehashPassword(ctx context.Context, user *model.User, password string) {
+	hashedPassword, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Printf("rehash password for %s failed: %v", user.ID.Hex(), err)
+		return
+	}
+	if err := db.ReplacePasswordHash(ctx, user.ID, user.Password, hashedPassword); err != nil {
+		log.Printf("rehash password for %s failed: %v", user.ID.Hex(), err)
+	}
+}
+
+// checkPasswordReuse rejects the current password and any password kept in
+// the user's history.
+func (s *authService) checkPasswordReuse(user *model.User, password string) error {
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > db.PASSWORD_HISTORY_SIZE+1 {
+		hashes = hashes[:db.PASSWORD_HISTORY_SIZE+1]
+	}
+	for _, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		if match, _ := s.hasher.Verify(password, hash); match {
+			return ErrPasswordReused
+		}
+	}
+	return nil
 }
 
 func (s *authService) GetUserByID(ctx context.Context, id primitive.ObjectID) (*model.User, error) {
@@ -112,6 +485,32 @@ func (s *authService) GetUserByID(ctx context.Context, id primitive.ObjectID) (*
 	return user, nil
 }
 
+// ViewUser returns the user with id as the caller may see them. Callers can
+// look up other users only with users.read; without it they only see
+// themselves, and none of the attributes that only admins can set.
+func (s *authService) ViewUser(ctx context.Context, callerID, id primitive.ObjectID) (*model.User, error) {
+	canRead, err := s.HasPermission(ctx, callerID, model.PermissionUsersRead)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead && callerID != id {
+		return nil, ErrForbidden
+	}
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead {
+		schema, err := s.attributeSchema(ctx)
+		if err != nil {
+			return nil, err
+		}
+		user.Attributes = userEditableAttributes(schema, user.Attributes)
+	}
+	return user, nil
+}
+
 func (s *authService) Logout(ctx context.Context, token string) error {
 	_, claims, err := utils.ParseJWT(token)
 	if err != nil {
@@ -121,57 +520,121 @@ func (s *authService) Logout(ctx context.Context, token string) error {
 	expUnix := int64(claims["exp"].(float64))
 	exp := time.Unix(expUnix, 0)
 
+	if sid, ok := claims["sid"].(string); ok {
+		if sessionID, err := primitive.ObjectIDFromHex(sid); err == nil {
+			if err := repository.RevokeSession(ctx, sessionID); err != nil {
+				return err
+			}
+		}
+	}
+
 	return repository.BlacklistToken(token, exp)
 }
 
-func (s *authService) IsAdmin(ctx context.Context, id primitive.ObjectID) (bool, error) {
-	user, err := repository.GetUserByID(id)
+// AddRole gives the user newRole on top of the roles it already holds.
+// Adding a role the user already holds changes nothing.
+func (s *authService) AddRole(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, newRole string) error {
+	if err := s.validateRole(ctx, "new_role", newRole); err != nil {
+		return err
+	}
+
+	target, err := s.GetUserByID(ctx, targetUserID)
 	if err != nil {
-		return false, ErrNotFound
+		return err
+	}
+
+	added, err := repository.AddUserRole(ctx, targetUserID, newRole)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
 	}
-	if user == nil {
-		return false, ErrNotFound
+	if err != nil || !added {
+		return err
 	}
-	return user.Role == "admin", nil
+
+	s.recordAdminAction(ctx, adminUserID, targetUserID, model.AuditActionRoleChange, map[string]interface{}{
+		"from": target.Roles,
+		"to":   append(append([]string{}, target.Roles...), newRole),
+	})
+	return nil
 }
 
-func (s *authService) AddRole(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, newRole string) error {
-	isAdmin, err := s.IsAdmin(ctx, adminUserID)
+// RemoveRole takes role away from the user. A user keeps at least one role,
+// and the last user able to manage roles cannot lose that permission.
+func (s *authService) RemoveRole(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, role string) error {
+	target, err := s.GetUserByID(ctx, targetUserID)
 	if err != nil {
 		return err
 	}
-	if !isAdmin {
-		return ErrForbidden
+	if !target.HasRole(role) {
+		return newFieldError("role", "ROLE_NOT_HELD", fmt.Sprintf("user does not hold role %q", role))
 	}
 
-	if newRole != "user" && newRole != "admin" {
-		return ErrInvalidArgument
+	remaining := []string{}
+	for _, r := range target.Roles {
+		if r != role {
+			remaining = append(remaining, r)
+		}
+	}
+	if len(remaining) == 0 {
+		return newFieldError("role", "LAST_ROLE", "a user must keep at least one role")
+	}
+	if err := s.ensureAdminRemains(ctx, target, remaining); err != nil {
+		return err
 	}
 
-	updateData := map[string]interface{}{
-		"role": newRole,
+	removed, err := repository.RemoveUserRole(ctx, targetUserID, role)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil || !removed {
+		return err
 	}
 
-	return repository.UpdateUser(targetUserID, updateData)
+	s.recordAdminAction(ctx, adminUserID, targetUserID, model.AuditActionRoleChange, map[string]interface{}{
+		"from": target.Roles,
+		"to":   remaining,
+	})
+	return nil
 }
 
-func (s *authService) ListUsers(ctx context.Context, filter *model.UserFilter) ([]*model.User, int64, error) {
-	userIDStr, ok := ctx.Value("user_id").(string)
-	if !ok {
-		return nil, 0, ErrUnauthenticated
+// UpdateUserRoles replaces every role of the user with roles.
+func (s *authService) UpdateUserRoles(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, roles []string) (*model.User, error) {
+	roles, err := s.validateRoles(ctx, "roles", roles)
+	if err != nil {
+		return nil, err
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDStr)
+	target, err := s.GetUserByID(ctx, targetUserID)
 	if err != nil {
-		return nil, 0, ErrInvalidArgument
+		return nil, err
+	}
+	if sameRoles(roles, target.Roles) {
+		return target, nil
+	}
+	if err := s.ensureAdminRemains(ctx, target, roles); err != nil {
+		return nil, err
 	}
 
-	isAdmin, err := s.IsAdmin(ctx, userID)
+	err = repository.UpdateUserFields(ctx, targetUserID, bson.M{"roles": roles}, nil)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
-	if !isAdmin {
-		return nil, 0, ErrForbidden
+
+	s.recordAdminAction(ctx, adminUserID, targetUserID, model.AuditActionRoleChange, map[string]interface{}{
+		"from": target.Roles,
+		"to":   roles,
+	})
+	return s.GetUserByID(ctx, targetUserID)
+}
+
+func (s *authService) ListUsers(ctx context.Context, filter *model.UserFilter) ([]*model.User, int64, error) {
+	var err error
+	filter.Attributes, err = s.attributeFilter(ctx, filter.Attributes)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	users, total, err := repository.ListUsers(ctx, filter)
@@ -182,25 +645,180 @@ func (s *authService) ListUsers(ctx context.Context, filter *model.UserFilter) (
 	return users, total, nil
 }
 
-func (s *authService) UpdateProfile(ctx context.Context, id primitive.ObjectID, newName, newEmail string) error {
-	if newEmail != "" && !utils.ValidEmail(newEmail) {
+// UpdateProfile changes the name and the given custom attributes right away.
+// A new email address only takes effect once it is confirmed through the link
+// sent to it; the returned flag reports whether such a change is pending.
+func (s *authService) UpdateProfile(ctx context.Context, id primitive.ObjectID, newName, newEmail string, attributes map[string]interface{}) (bool, error) {
+	if newName == "" || newEmail == "" {
+		return false, ErrInvalidArgument
+	}
+	newEmail, err := utils.NormalizeEmail(newEmail)
+	if err != nil {
+		return false, ErrInvalidArgument
+	}
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	// Validate everything before writing so a rejected call changes nothing.
+	emailChanged := newEmail != user.Email
+	if emailChanged {
+		taken, err := repository.IsEmailTaken(ctx, newEmail, id)
+		if err != nil {
+			return false, err
+		}
+		if taken {
+			return false, ErrUserExists
+		}
+	}
+
+	set := bson.M{"name": newName}
+	if len(attributes) > 0 {
+		merged, err := s.mergeAttributes(ctx, user.Attributes, attributes, false)
+		if err != nil {
+			return false, err
+		}
+		set["attributes"] = merged
+	}
+	if err := repository.UpdateUserFields(ctx, id, set, nil); err != nil {
+		return false, err
+	}
+
+	if !emailChanged {
+		return false, nil
+	}
+
+	if err := repository.SetPendingEmail(ctx, id, newEmail); err != nil {
+		return false, err
+	}
+
+	if err := s.sendEmailChange(ctx, user, newEmail); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *authService) sendEmailChange(ctx context.Context, user *model.User, newEmail string) error {
+	confirmToken, err := s.issueEmailToken(ctx, user.ID, model.TokenPurposeEmailChange, newEmail, s.emailChangeTTL)
+	if err != nil {
+		return err
+	}
+	confirmLink, err := buildLink(s.emailChangeURL, confirmToken)
+	if err != nil {
+		return err
+	}
+
+	revertToken, err := s.issueEmailToken(ctx, user.ID, model.TokenPurposeEmailChangeRevert, user.Email, s.emailChangeRevertTTL)
+	if err != nil {
+		return err
+	}
+	revertLink, err := buildLink(s.emailChangeRevertURL, revertToken)
+	if err != nil {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nOpen the link below to start using this address for your account. It expires in %s.\n\n%s\n\nIf you did not request this, you can ignore this email.\n",
+			user.Name, s.emailChangeTTL, confirmLink),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf("Hi %s,\n\nA request was made to change the email address on your account to %s.\n\nIf this was not you, open the link below within %s to keep this address and sign out all sessions.\n\n%s\n",
+			user.Name, newEmail, s.emailChangeRevertTTL, revertLink),
+	})
+}
+
+// issueEmailToken is issueActionToken for tokens bound to an email address.
+// Revert tokens are the exception to the invalidation: each one lets the
+// owner of an earlier address undo the change until it expires, so a second
+// change cannot void the link already sent to the original address.
+func (s *authService) issueEmailToken(ctx context.Context, userID primitive.ObjectID, purpose, email string, ttl time.Duration) (string, error) {
+	if purpose != model.TokenPurposeEmailChangeRevert {
+		if err := repository.InvalidateActionTokens(ctx, userID, purpose); err != nil {
+			return "", err
+		}
+	}
+
+	token, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = repository.CreateActionToken(ctx, &model.ActionToken{
+		TokenHash: hash,
+		UserID:    userID,
+		Purpose:   purpose,
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *authService) ConfirmEmailChange(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidArgument
+	}
+
+	actionToken, err := repository.ConsumeActionToken(ctx, utils.HashToken(token), model.TokenPurposeEmailChange)
+	if err != nil {
 		return ErrInvalidArgument
 	}
 
-	updateData := make(map[string]interface{})
-	if newName == "" {
+	matched, err := repository.ConfirmPendingEmail(ctx, actionToken.UserID, actionToken.Email)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrUserExists
+	}
+	if err != nil {
+		return err
+	}
+	if !matched {
+		// The change was reverted or superseded by a newer request.
 		return ErrInvalidArgument
 	}
-	if newEmail == "" {
+	return nil
+}
+
+func (s *authService) RevertEmailChange(ctx context.Context, token string) error {
+	if token == "" {
 		return ErrInvalidArgument
 	}
-	updateData["name"] = newName
-	updateData["email"] = newEmail
-	if len(updateData) == 0 {
+
+	actionToken, err := repository.ConsumeActionToken(ctx, utils.HashToken(token), model.TokenPurposeEmailChangeRevert)
+	if err != nil {
 		return ErrInvalidArgument
 	}
 
-	return repository.UpdateUser(id, updateData)
+	if err := repository.InvalidateActionTokens(ctx, actionToken.UserID, model.TokenPurposeEmailChange); err != nil {
+		return err
+	}
+	// Revert links issued for later changes point at addresses that replaced
+	// this one and must not be able to undo the revert. Older ones stay valid.
+	if err := repository.InvalidateActionTokensIssuedAfter(ctx, actionToken.UserID, model.TokenPurposeEmailChangeRevert, actionToken.CreatedAt); err != nil {
+		return err
+	}
+
+	err = repository.RevertEmail(ctx, actionToken.UserID, actionToken.Email)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrUserExists
+	}
+	if err != nil {
+		return err
+	}
+
+	return repository.RevokeUserSessions(ctx, actionToken.UserID, primitive.NilObjectID)
 }
 
 func (s *authService) DeleteProfile(ctx context.Context, userID primitive.ObjectID) error {
@@ -215,49 +833,269 @@ func (s *authService) DeleteProfile(ctx context.Context, userID primitive.Object
 		return ErrNotFound
 	}
 
-	update := map[string]interface{}{
-		"deleted":    true,
-		"updated_at": time.Now(),
+	if err := repository.MarkUserDeleted(ctx, userID, time.Now().Add(s.deletionGracePeriod)); err != nil {
+		return err
 	}
-	return repository.UpdateUser(userID, update)
+
+	return repository.RevokeUserSessions(ctx, userID, primitive.NilObjectID)
 }
 
-func (s *authService) GeneratePasswordResetToken(ctx context.Context, userID primitive.ObjectID) (string, error) {
+// GeneratePasswordResetToken emails a reset link to the signed-in user. The
+// token only ever travels by email.
+func (s *authService) GeneratePasswordResetToken(ctx context.Context, userID primitive.ObjectID) error {
 	user, err := s.GetUserByID(ctx, userID)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	return s.sendPasswordReset(ctx, user, false)
+}
+
+func (s *authService) RequestPasswordReset(ctx context.Context, email string) error {
+	if email == "" {
+		return ErrInvalidArgument
 	}
-	email := user.Email
-	fmt.Println(email)
 
-	token, err := utils.GenerateResetToken(user.ID.Hex())
+	email, err := utils.NormalizeEmail(email)
 	if err != nil {
-		return "", err
+		return ErrInvalidArgument
 	}
 
-	return token, nil
+	key := fmt.Sprintf("password_reset:%s", email)
+	allowed, err := utils.RateLimit(ctx, key, 3, time.Hour)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return nil
+	}
+
+	user, err := repository.GetUserByEmail(email)
+	if err != nil || user == nil {
+		// Respond the same way whether or not the account exists.
+		return nil
+	}
+
+	if err := s.sendPasswordReset(ctx, user, false); err != nil {
+		log.Printf("password reset for %s failed: %v", user.ID.Hex(), err)
+	}
+	return nil
+}
+
+func (s *authService) sendPasswordReset(ctx context.Context, user *model.User, forced bool) error {
+	token, err := s.issueActionToken(ctx, user.ID, model.TokenPurposePasswordReset, s.passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link, err := buildLink(s.passwordResetURL, token)
+	if err != nil {
+		return err
+	}
+
+	intro := "Use the link below to reset your password."
+	outro := "If you did not request this, you can ignore this email."
+	if forced {
+		intro = "An administrator has reset your password and signed you out everywhere. Use the link below to choose a new one."
+		outro = "Contact support if you have questions about this change."
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n%s It expires in %s and can only be used once.\n\n%s\n\n%s\n",
+			user.Name, intro, s.passwordResetTTL, link, outro),
+	})
+}
+
+// issueActionToken invalidates any outstanding token with the same purpose and
+// stores the hash of a fresh one.
+func (s *authService) issueActionToken(ctx context.Context, userID primitive.ObjectID, purpose string, ttl time.Duration) (string, error) {
+	return s.issueEmailToken(ctx, userID, purpose, "", ttl)
+}
+
+func buildLink(base, token string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
 }
 
 func (s *authService) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
-	userIDHex, err := utils.ValidateResetToken(resetToken)
+	if resetToken == "" || newPassword == "" {
+		return ErrInvalidArgument
+	}
+
+	tokenHash := utils.HashToken(resetToken)
+	token, err := repository.GetActionToken(ctx, tokenHash, model.TokenPurposePasswordReset)
 	if err != nil {
 		return ErrInvalidArgument
 	}
 
-	userID, err := primitive.ObjectIDFromHex(userIDHex)
+	user, err := s.GetUserByID(ctx, token.UserID)
 	if err != nil {
+		return err
+	}
+
+	if err := s.validateNewPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+
+	if err := s.checkPasswordReuse(user, newPassword); err != nil {
+		return err
+	}
+
+	if _, err := repository.ConsumeActionToken(ctx, tokenHash, model.TokenPurposePasswordReset); err != nil {
+		return ErrInvalidArgument
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	err = db.UpdatePassword(ctx, token.UserID, hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	// Proving control of the mailbox is enough to lift a lockout as well.
+	if err := repository.ClearLockout(ctx, token.UserID); err != nil {
+		return err
+	}
+
+	return repository.RevokeUserSessions(ctx, token.UserID, primitive.NilObjectID)
+}
+
+func (s *authService) ChangePassword(ctx context.Context, userID, sessionID primitive.ObjectID, currentPassword, newPassword string) error {
+	if currentPassword == "" || newPassword == "" {
 		return ErrInvalidArgument
 	}
 
-	hashedPassword, err := utils.HashPassword(newPassword)
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if match, _ := s.hasher.Verify(currentPassword, user.Password); !match {
+		return ErrInvalidCredentials
+	}
+
+	if err := s.validateNewPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+
+	if err := s.checkPasswordReuse(user, newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := db.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		return err
+	}
+
+	return repository.RevokeUserSessions(ctx, userID, sessionID)
+}
+
+func (s *authService) ForcePasswordReset(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) error {
+	user, err := s.GetUserByID(ctx, targetUserID)
 	if err != nil {
 		return err
 	}
+	if err := s.requireCredentialAccess(ctx, adminUserID, user); err != nil {
+		return err
+	}
+
+	if err := db.InvalidatePassword(ctx, user.ID, adminUserID); err != nil {
+		return err
+	}
+
+	if err := repository.RevokeUserSessions(ctx, user.ID, primitive.NilObjectID); err != nil {
+		return err
+	}
+
+	err = repository.RecordAudit(ctx, &model.AuditEntry{
+		ActorID:      adminUserID,
+		Action:       model.AuditActionForcePasswordReset,
+		TargetUserID: user.ID,
+	})
+	if err != nil {
+		log.Printf("audit %s for %s failed: %v", model.AuditActionForcePasswordReset, user.ID.Hex(), err)
+	}
+
+	return s.sendPasswordReset(ctx, user, true)
+}
+
+func (s *authService) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidArgument
+	}
+
+	actionToken, err := repository.ConsumeActionToken(ctx, utils.HashToken(token), model.TokenPurposeEmailVerification)
+	if err != nil {
+		return ErrInvalidArgument
+	}
+
+	return repository.UpdateUser(actionToken.UserID, map[string]interface{}{
+		"email_verified":    true,
+		"email_verified_at": time.Now(),
+	})
+}
+
+func (s *authService) ResendVerification(ctx context.Context, email string) error {
+	if email == "" {
+		return ErrInvalidArgument
+	}
+
+	email, err := utils.NormalizeEmail(email)
+	if err != nil {
+		return ErrInvalidArgument
+	}
 
-	err = db.UpdatePassword(ctx, userID, hashedPassword)
+	key := fmt.Sprintf("resend_verification:%s", email)
+	allowed, err := utils.RateLimit(ctx, key, 3, time.Hour)
 	if err != nil {
 		return err
 	}
+	if !allowed {
+		return nil
+	}
+
+	user, err := repository.GetUserByEmail(email)
+	if err != nil || user == nil || user.EmailVerified {
+		// Respond the same way whether or not there is anything to verify.
+		return nil
+	}
 
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("verification email for %s failed: %v", user.ID.Hex(), err)
+	}
 	return nil
 }
+
+func (s *authService) sendVerificationEmail(ctx context.Context, user *model.User) error {
+	token, err := s.issueActionToken(ctx, user.ID, model.TokenPurposeEmailVerification, s.emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link, err := buildLink(s.emailVerificationURL, token)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s\n\nIf you did not create an account, you can ignore this email.\n",
+			user.Name, s.emailVerificationTTL, link),
+	})
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"context"
+	"encoding/base64"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const checkpointPrefix = "v1:"
+
+// EncodeExportCheckpoint returns the opaque token that resumes an export
+// after the given user.
+func EncodeExportCheckpoint(lastID primitive.ObjectID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(checkpointPrefix + lastID.Hex()))
+}
+
+func decodeExportCheckpoint(token string) (primitive.ObjectID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(raw), checkpointPrefix) {
+		return primitive.NilObjectID, newFieldError("checkpoint", "INVALID_CHECKPOINT", "checkpoint is not a valid export checkpoint")
+	}
+	id, err := primitive.ObjectIDFromHex(strings.TrimPrefix(string(raw), checkpointPrefix))
+	if err != nil {
+		return primitive.NilObjectID, newFieldError("checkpoint", "INVALID_CHECKPOINT", "checkpoint is not a valid export checkpoint")
+	}
+	return id, nil
+}
+
+// ExportUsers calls fn for every user matching filter, in a stable order.
+// A non-empty checkpoint, as returned by EncodeExportCheckpoint, resumes
+// after the user it was issued for.
+func (s *authService) ExportUsers(ctx context.Context, adminUserID primitive.ObjectID, filter *model.UserExportFilter, checkpoint string, fn func(*model.User) error) error {
+	if checkpoint != "" {
+		after, err := decodeExportCheckpoint(checkpoint)
+		if err != nil {
+			return err
+		}
+		filter.After = after
+	}
+	if filter.Role != "" {
+		if err := s.validateRole(ctx, "role", filter.Role); err != nil {
+			return err
+		}
+	}
+
+	exported := 0
+	err := repository.StreamUsers(ctx, filter, func(u *model.User) error {
+		exported++
+		return fn(u)
+	})
+
+	s.recordAdminAction(ctx, adminUserID, primitive.NilObjectID, model.AuditActionExportUsers, map[string]interface{}{
+		"exported":  exported,
+		"resumed":   checkpoint != "",
+		"completed": err == nil,
+	})
+	return err
+}
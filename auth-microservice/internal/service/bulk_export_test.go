@@ -0,0 +1,48 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDecodeExportCheckpoint(t *testing.T) {
+	id := primitive.NewObjectID()
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    primitive.ObjectID
+		wantErr bool
+	}{
+		{name: "round trip", token: EncodeExportCheckpoint(id), want: id},
+		{name: "not base64", token: "not a checkpoint!", wantErr: true},
+		{name: "missing prefix", token: encode(id.Hex()), wantErr: true},
+		{name: "unknown version", token: encode("v2:" + id.Hex()), wantErr: true},
+		{name: "malformed id", token: encode(checkpointPrefix + "xyz"), wantErr: true},
+		{name: "empty id", token: encode(checkpointPrefix), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeExportCheckpoint(tt.token)
+			if tt.wantErr {
+				var validation *ValidationError
+				if !errors.As(err, &validation) || validation.Violations[0].Reason != "INVALID_CHECKPOINT" {
+					t.Errorf("decodeExportCheckpoint(%q) error = %v, want INVALID_CHECKPOINT", tt.token, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeExportCheckpoint(%q): %v", tt.token, err)
+			}
+			if got != tt.want {
+				t.Errorf("decodeExportCheckpoint(%q) = %s, want %s", tt.token, got.Hex(), tt.want.Hex())
+			}
+		})
+	}
+}
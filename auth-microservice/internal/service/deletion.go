@@ -0,0 +1,150 @@
+package service
+
+import (
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"auth-microservice/internal/utils"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// What the purger does with accounts whose grace period has ended, selected
+// with ACCOUNT_PURGE_MODE.
+const (
+	PurgeModeAnonymize = "anonymize"
+	PurgeModeDelete    = "delete"
+)
+
+const purgeBatchSize = 100
+
+// RestoreAccount undoes DeleteProfile while the account is still inside its
+// grace period. The caller proves ownership with the account's password.
+func (s *authService) RestoreAccount(ctx context.Context, email, password string) error {
+	if email == "" || password == "" {
+		return ErrInvalidArgument
+	}
+
+	email, err := utils.NormalizeEmail(email)
+	if err != nil {
+		return ErrInvalidCredentials
+	}
+
+	// Same budget as Login, so restoring cannot be used to guess passwords.
+	key := fmt.Sprintf("restore_attempts:%s", email)
+	allowed, err := utils.RateLimit(ctx, key, 5, time.Minute)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+
+	user, err := repository.GetRestorableUserByEmail(ctx, email)
+	if err != nil {
+		return ErrInvalidCredentials
+	}
+
+	if match, _ := s.hasher.Verify(password, user.Password); !match {
+		return ErrInvalidCredentials
+	}
+
+	err = repository.RestoreUser(ctx, user.ID)
+	if mongo.IsDuplicateKeyError(err) {
+		// The address was registered again while the account was deleted.
+		return ErrUserExists
+	}
+	if err == mongo.ErrNoDocuments {
+		return ErrInvalidCredentials
+	}
+	return err
+}
+
+func (s *authService) ListPendingDeletions(ctx context.Context, page, limit int64) ([]*model.User, int64, error) {
+	return repository.ListPendingDeletions(ctx, page, limit)
+}
+
+// ExpediteDeletion ends the grace period of a deleted account and purges it
+// right away.
+func (s *authService) ExpediteDeletion(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) error {
+	err := repository.SetPurgeAfter(ctx, targetUserID, time.Now())
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	err = repository.RecordAudit(ctx, &model.AuditEntry{
+		ActorID:      adminUserID,
+		Action:       model.AuditActionExpediteDeletion,
+		TargetUserID: targetUserID,
+	})
+	if err != nil {
+		log.Printf("audit %s for %s failed: %v", model.AuditActionExpediteDeletion, targetUserID.Hex(), err)
+	}
+
+	return s.purgeUser(ctx, targetUserID)
+}
+
+// PurgeExpiredAccounts purges every deleted account whose grace period has
+// ended and returns how many were purged. Accounts that fail to purge are
+// logged and skipped, so they are retried on the next run without holding
+// up the others.
+func (s *authService) PurgeExpiredAccounts(ctx context.Context) (int, error) {
+	purged := 0
+	var failed []primitive.ObjectID
+	for {
+		ids, err := repository.FindUsersDueForPurge(ctx, time.Now(), failed, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		if len(ids) == 0 {
+			if len(failed) > 0 {
+				return purged, fmt.Errorf("%d accounts could not be purged", len(failed))
+			}
+			return purged, nil
+		}
+
+		for _, id := range ids {
+			if err := s.purgeUser(ctx, id); err != nil {
+				log.Printf("purging account %s failed: %v", id.Hex(), err)
+				failed = append(failed, id)
+				continue
+			}
+			purged++
+		}
+	}
+}
+
+func (s *authService) purgeUser(ctx context.Context, userID primitive.ObjectID) error {
+	if err := repository.DeleteUserActionTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err := repository.DeleteUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	if err := repository.DeleteUserLogins(ctx, userID); err != nil {
+		return err
+	}
+	if err := repository.DeleteUserPolicyAcceptances(ctx, userID); err != nil {
+		return err
+	}
+	// Audit entries and invitations outlive the account but must not keep
+	// its personal data.
+	if err := repository.RedactUserAuditEntries(ctx, userID); err != nil {
+		return err
+	}
+	if err := repository.RedactUserInvitations(ctx, userID); err != nil {
+		return err
+	}
+
+	if s.purgeMode == PurgeModeDelete {
+		return repository.HardDeleteUser(ctx, userID)
+	}
+	return repository.AnonymizeUser(ctx, userID)
+}
@@ -0,0 +1,27 @@
+package service
+
+import "strings"
+
+type FieldViolation struct {
+	Field       string
+	Reason      string
+	Description string
+}
+
+// ValidationError reports one or more invalid request fields. Handlers turn
+// it into InvalidArgument with the individual violations as error details.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Field+": "+v.Description)
+	}
+	return "invalid argument: " + strings.Join(msgs, "; ")
+}
+
+func newFieldError(field, reason, description string) error {
+	return &ValidationError{Violations: []FieldViolation{{Field: field, Reason: reason, Description: description}}}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ExportMyData collects everything stored about the user for a data subject
+// access request.
+func (s *authService) ExportMyData(ctx context.Context, userID primitive.ObjectID) (*model.UserDataExport, error) {
+	return s.buildUserDataExport(ctx, userID)
+}
+
+// AdminExportUserData runs the same export as ExportMyData on behalf of a
+// user and records who requested it.
+func (s *authService) AdminExportUserData(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) (*model.UserDataExport, error) {
+	export, err := s.buildUserDataExport(ctx, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repository.RecordAudit(ctx, &model.AuditEntry{
+		ActorID:      adminUserID,
+		Action:       model.AuditActionExportUserData,
+		TargetUserID: targetUserID,
+	})
+	if err != nil {
+		log.Printf("audit %s for %s failed: %v", model.AuditActionExportUserData, targetUserID.Hex(), err)
+	}
+	return export, nil
+}
+
+// buildUserDataExport includes accounts in their deletion grace period, whose
+// data is still stored.
+func (s *authService) buildUserDataExport(ctx context.Context, userID primitive.ObjectID) (*model.UserDataExport, error) {
+	user, err := repository.GetUserByIDAnyState(ctx, userID)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := repository.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	logins, err := repository.ListUserLogins(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	acceptances, err := repository.ListUserPolicyAcceptances(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	audits, err := repository.ListUserAuditEntries(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &model.UserDataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: model.ExportProfile{
+			ID:                 user.ID.Hex(),
+			Name:               user.Name,
+			Email:              user.Email,
+			EmailVerified:      user.EmailVerified,
+			EmailVerifiedAt:    optionalTime(user.EmailVerifiedAt),
+			PendingEmail:       user.PendingEmail,
+			Roles:              user.Roles,
+			PasswordChangedAt:  optionalTime(user.PasswordChangedAt),
+			MustChangePassword: user.MustChangePassword,
+			Suspended:          user.IsSuspended(time.Now()),
+			SuspendedUntil:     optionalTime(user.SuspendedUntil),
+			SuspensionReason:   user.SuspensionReason,
+			Locked:             isLocked(user, time.Now()),
+			LockedUntil:        optionalTime(user.LockedUntil),
+			Attributes:         user.Attributes,
+			Deleted:            user.Deleted,
+			PurgeAfter:         optionalTime(user.PurgeAfter),
+			CreatedAt:          user.CreatedAt,
+		},
+		RoleHistory:       []model.ExportRoleChange{},
+		Sessions:          []model.ExportSession{},
+		LoginHistory:      []model.ExportLoginEvent{},
+		PolicyAcceptances: []model.ExportPolicyAcceptance{},
+		AuditEntries:      []model.ExportAuditEntry{},
+	}
+
+	for _, sess := range sessions {
+		export.Sessions = append(export.Sessions, model.ExportSession{
+			ID:        sess.ID.Hex(),
+			Scope:     sess.Scope,
+			CreatedAt: sess.CreatedAt,
+			ExpiresAt: sess.ExpiresAt,
+			RevokedAt: sess.RevokedAt,
+		})
+	}
+
+	for _, event := range logins {
+		export.LoginHistory = append(export.LoginHistory, model.ExportLoginEvent{
+			Success:   event.Success,
+			Reason:    event.Reason,
+			IP:        event.IP,
+			UserAgent: event.UserAgent,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	for _, acceptance := range acceptances {
+		export.PolicyAcceptances = append(export.PolicyAcceptances, model.ExportPolicyAcceptance{
+			Type:       acceptance.Type,
+			Version:    acceptance.Version,
+			IP:         acceptance.IP,
+			UserAgent:  acceptance.UserAgent,
+			AcceptedAt: acceptance.AcceptedAt,
+		})
+	}
+
+	for _, entry := range audits {
+		if entry.Action == model.AuditActionRoleChange && entry.TargetUserID == userID {
+			export.RoleHistory = append(export.RoleHistory, model.ExportRoleChange{
+				ChangedBy: entry.ActorID.Hex(),
+				From:      auditRoles(entry.Details["from"]),
+				To:        auditRoles(entry.Details["to"]),
+				ChangedAt: entry.CreatedAt,
+			})
+		}
+		export.AuditEntries = append(export.AuditEntries, model.ExportAuditEntry{
+			ActorID:      entry.ActorID.Hex(),
+			Action:       entry.Action,
+			TargetUserID: entry.TargetUserID.Hex(),
+			Details:      entry.Details,
+			CreatedAt:    entry.CreatedAt,
+		})
+	}
+
+	return export, nil
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// auditRoles reads the roles of a role change audit entry. Entries written
+// before users could hold several roles store a single string.
+func auditRoles(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return []string{}
+		}
+		return []string{v}
+	case primitive.A:
+		roles := make([]string, 0, len(v))
+		for _, r := range v {
+			roles = append(roles, fmt.Sprint(r))
+		}
+		return roles
+	case []string:
+		return v
+	}
+	return []string{}
+}
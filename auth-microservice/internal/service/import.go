@@ -0,0 +1,272 @@
+package service
+
+import (
+	"auth-microservice/internal/db"
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"auth-microservice/internal/utils"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ImportUsers reads records from next until it returns io.EOF and hands a
+// result for each of them to report. A record that fails validation does not
+// stop the import; an error from next or report does.
+func (s *authService) ImportUsers(ctx context.Context, adminUserID primitive.ObjectID, opts model.ImportOptions,
+	next func() (*model.ImportRecord, error), report func(*model.ImportResult) error) error {
+	switch opts.Mode {
+	case "":
+		opts.Mode = model.ImportModeSkipExisting
+	case model.ImportModeSkipExisting, model.ImportModeUpsert:
+	default:
+		return newFieldError("mode", "INVALID_MODE", "mode must be skip_existing or upsert")
+	}
+
+	// Giving imported users any role but the default, or changing the role
+	// of an existing user, needs roles.assign as well.
+	canAssignRoles, err := s.HasPermission(ctx, adminUserID, model.PermissionRolesAssign)
+	if err != nil {
+		return err
+	}
+	// Upserts may only change the credentials of users whose permissions the
+	// admin holds as well, see requireCredentialAccess.
+	adminRoles, err := s.callerRoles(ctx, adminUserID)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	defer func() {
+		s.recordAdminAction(ctx, adminUserID, primitive.NilObjectID, model.AuditActionImportUsers, map[string]interface{}{
+			"mode":    opts.Mode,
+			"dry_run": opts.DryRun,
+			"created": counts[model.ImportStatusCreated],
+			"updated": counts[model.ImportStatusUpdated],
+			"skipped": counts[model.ImportStatusSkipped],
+			"failed":  counts[model.ImportStatusFailed],
+		})
+	}()
+
+	for i := 0; ; i++ {
+		record, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		result := s.importUser(ctx, record, opts, canAssignRoles, adminRoles)
+		result.Index = i
+		counts[result.Status]++
+
+		if err := report(result); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *authService) importUser(ctx context.Context, record *model.ImportRecord, opts model.ImportOptions, canAssignRoles bool, adminRoles []model.Role) *model.ImportResult {
+	result := &model.ImportResult{Email: record.Email}
+	fail := func(err error) *model.ImportResult {
+		result.Status = model.ImportStatusFailed
+		result.Err = err
+		return result
+	}
+
+	email, err := utils.NormalizeEmail(record.Email)
+	if err != nil {
+		return fail(newFieldError("email", "INVALID_EMAIL", "email address is not valid"))
+	}
+	result.Email = email
+
+	name := strings.TrimSpace(record.Name)
+	if name == "" {
+		return fail(newFieldError("name", "REQUIRED", "name must not be empty"))
+	}
+
+	roles := record.Roles
+	if len(roles) == 0 {
+		roles = []string{model.RoleUser}
+	}
+	roles, err = s.validateRoles(ctx, "roles", roles)
+	if err != nil {
+		return fail(err)
+	}
+
+	switch {
+	case record.Password != "" && record.PasswordHash != "":
+		return fail(newFieldError("password_hash", "CONFLICT", "set either password or password_hash, not both"))
+	case record.Password == "" && record.PasswordHash == "":
+		return fail(newFieldError("password", "REQUIRED", "password or password_hash is required"))
+	case record.PasswordHash != "" && !utils.IsSupportedPasswordHash(record.PasswordHash):
+		return fail(newFieldError("password_hash", "UNSUPPORTED_HASH", "password_hash must be an argon2id or bcrypt hash"))
+	}
+
+	existing, err := repository.GetUserByEmail(email)
+	if err == mongo.ErrNoDocuments {
+		existing = nil
+	} else if err != nil {
+		return fail(err)
+	}
+
+	if existing != nil {
+		result.UserID = existing.ID
+		if opts.Mode == model.ImportModeSkipExisting {
+			result.Status = model.ImportStatusSkipped
+			return result
+		}
+		if !sameRoles(existing.Roles, roles) && !canAssignRoles {
+			return fail(ErrForbidden)
+		}
+		if err := s.ensureAdminRemains(ctx, existing, roles); err != nil {
+			return fail(err)
+		}
+	} else if !sameRoles(roles, []string{model.RoleUser}) && !canAssignRoles {
+		return fail(ErrForbidden)
+	}
+
+	var attributes map[string]interface{}
+	if existing == nil {
+		attributes, err = s.newUserAttributes(ctx, record.Attributes, true)
+	} else if len(record.Attributes) > 0 {
+		attributes, err = s.mergeAttributes(ctx, existing.Attributes, record.Attributes, true)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	// An upsert that sets the password an existing user already has leaves
+	// it alone; any other password must not be one the user had before.
+	passwordUnchanged := false
+	if existing != nil {
+		if record.PasswordHash != "" {
+			passwordUnchanged = record.PasswordHash == existing.Password
+			if !passwordUnchanged && containsString(existing.PasswordHistory, record.PasswordHash) {
+				return fail(ErrPasswordReused)
+			}
+		} else if match, _ := s.hasher.Verify(record.Password, existing.Password); match {
+			passwordUnchanged = true
+		} else if err := s.checkPasswordReuse(existing, record.Password); err != nil {
+			return fail(err)
+		}
+
+		if !passwordUnchanged || record.EmailVerified != existing.EmailVerified ||
+			record.MustChangePassword != existing.MustChangePassword {
+			if err := s.requireRolesCover(ctx, adminRoles, existing); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	if record.Password != "" && !passwordUnchanged {
+		candidate := &model.User{Name: name, Email: email}
+		if err := s.validateNewPassword(ctx, candidate, record.Password); err != nil {
+			return fail(err)
+		}
+	}
+
+	if opts.DryRun {
+		result.Status = model.ImportStatusCreated
+		if existing != nil {
+			result.Status = model.ImportStatusUpdated
+		}
+		return result
+	}
+
+	hash := record.PasswordHash
+	if passwordUnchanged {
+		hash = existing.Password
+	} else if record.Password != "" {
+		if hash, err = s.hasher.Hash(record.Password); err != nil {
+			return fail(err)
+		}
+	}
+
+	if existing != nil {
+		if err := s.updateImportedUser(ctx, existing, record, name, roles, hash, attributes); err != nil {
+			return fail(err)
+		}
+		result.Status = model.ImportStatusUpdated
+		return result
+	}
+
+	now := time.Now()
+	user := &model.User{
+		ID:                 primitive.NewObjectID(),
+		Name:               name,
+		Email:              email,
+		Roles:              roles,
+		Password:           hash,
+		EmailVerified:      record.EmailVerified,
+		MustChangePassword: record.MustChangePassword,
+		PasswordChangedAt:  now,
+		CreatedAt:          now,
+		Attributes:         attributes,
+	}
+	if record.EmailVerified {
+		user.EmailVerifiedAt = now
+	}
+	if !record.CreatedAt.IsZero() {
+		user.CreatedAt = record.CreatedAt
+	}
+
+	err = repository.CreateUser(user)
+	if mongo.IsDuplicateKeyError(err) {
+		return fail(ErrUserExists)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	result.Status = model.ImportStatusCreated
+	result.UserID = user.ID
+	return result
+}
+
+// updateImportedUser applies an upserted record to existing. attributes is
+// nil when the record leaves them alone.
+func (s *authService) updateImportedUser(ctx context.Context, existing *model.User, record *model.ImportRecord, name string, roles []string, hash string, attributes map[string]interface{}) error {
+	passwordChanged := hash != existing.Password
+	if passwordChanged {
+		if err := db.UpdatePassword(ctx, existing.ID, hash); err != nil {
+			return err
+		}
+	}
+
+	set := bson.M{
+		"name":                 name,
+		"roles":                roles,
+		"email_verified":       record.EmailVerified,
+		"must_change_password": record.MustChangePassword,
+	}
+	if attributes != nil {
+		set["attributes"] = attributes
+	}
+	var unset []string
+	switch {
+	case record.EmailVerified && !existing.EmailVerified:
+		set["email_verified_at"] = time.Now()
+	case !record.EmailVerified:
+		unset = append(unset, "email_verified_at")
+	}
+
+	err := repository.UpdateUserFields(ctx, existing.ID, set, unset)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if passwordChanged {
+		return repository.RevokeUserSessions(ctx, existing.ID, primitive.NilObjectID)
+	}
+	return nil
+}
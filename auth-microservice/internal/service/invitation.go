@@ -0,0 +1,153 @@
+package service
+
+import (
+	"auth-microservice/internal/mailer"
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"auth-microservice/internal/utils"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InviteUser emails a single-use link that lets the recipient create an
+// account with the given role. Inviting the same address again replaces any
+// pending invitation.
+func (s *authService) InviteUser(ctx context.Context, adminUserID primitive.ObjectID, email, role string) (*model.Invitation, error) {
+	email, err := utils.NormalizeEmail(email)
+	if err != nil {
+		return nil, newFieldError("email", "INVALID_EMAIL", "email address is not valid")
+	}
+	if role == "" {
+		role = model.RoleUser
+	}
+	if err := s.validateRole(ctx, "role", role); err != nil {
+		return nil, err
+	}
+	if err := s.requireRoleAssignment(ctx, adminUserID, []string{role}); err != nil {
+		return nil, err
+	}
+
+	taken, err := repository.IsEmailTaken(ctx, email, primitive.NilObjectID)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrUserExists
+	}
+
+	if err := repository.RevokePendingInvitationsForEmail(ctx, email); err != nil {
+		return nil, err
+	}
+
+	token, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &model.Invitation{
+		Email:     email,
+		Role:      role,
+		TokenHash: hash,
+		InvitedBy: adminUserID,
+		ExpiresAt: time.Now().Add(s.invitationTTL),
+	}
+	if err := repository.CreateInvitation(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	link, err := buildLink(s.invitationURL, token)
+	if err != nil {
+		return nil, err
+	}
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "You have been invited",
+		Body: fmt.Sprintf("Hi,\n\nYou have been invited to create an account. Open the link below to choose your name and password. It expires in %s.\n\n%s\n\nIf you were not expecting this, you can ignore this email.\n",
+			s.invitationTTL, link),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, primitive.NilObjectID, model.AuditActionInviteUser, map[string]interface{}{
+		"invitation_id": invitation.ID.Hex(),
+		"email":         email,
+		"role":          role,
+	})
+	return invitation, nil
+}
+
+// AcceptInvitation creates the invited account. The invitation proves that
+// the invitee controls the address, so the email starts out verified.
+func (s *authService) AcceptInvitation(ctx context.Context, token, name, password string, attributes map[string]interface{}) (*model.User, error) {
+	if token == "" {
+		return nil, ErrInvalidArgument
+	}
+
+	attributes, err := s.newUserAttributes(ctx, attributes, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Claiming the invitation first means one that is revoked or expires
+	// meanwhile, or is accepted twice, never yields an account.
+	userID := primitive.NewObjectID()
+	invitation, err := repository.ClaimInvitation(ctx, utils.HashToken(token), userID)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidArgument
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &model.User{
+		ID:              userID,
+		Name:            name,
+		Email:           invitation.Email,
+		Password:        password,
+		Roles:           []string{invitation.Role},
+		Attributes:      attributes,
+		EmailVerified:   true,
+		EmailVerifiedAt: now,
+	}
+	if err := s.createUser(ctx, user); err != nil {
+		if releaseErr := repository.ReleaseInvitation(ctx, invitation.ID, userID); releaseErr != nil {
+			log.Printf("releasing invitation %s failed: %v", invitation.ID.Hex(), releaseErr)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *authService) ListInvitations(ctx context.Context, status string, page, limit int64) ([]*model.Invitation, int64, error) {
+	switch status {
+	case "", model.InvitationStatusPending, model.InvitationStatusAccepted,
+		model.InvitationStatusRevoked, model.InvitationStatusExpired:
+	default:
+		return nil, 0, newFieldError("status", "INVALID_STATUS", "status must be pending, accepted, revoked or expired")
+	}
+
+	return repository.ListInvitations(ctx, status, page, limit)
+}
+
+func (s *authService) RevokeInvitation(ctx context.Context, adminUserID, invitationID primitive.ObjectID) error {
+	invitation, err := repository.RevokeInvitation(ctx, invitationID)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, primitive.NilObjectID, model.AuditActionRevokeInvitation, map[string]interface{}{
+		"invitation_id": invitation.ID.Hex(),
+		"email":         invitation.Email,
+	})
+	return nil
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"auth-microservice/internal/mailer"
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"auth-microservice/internal/utils"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// isLocked reports whether the account is locked at now, either permanently
+// or by a lockout window that has not expired yet.
+func isLocked(user *model.User, now time.Time) bool {
+	return user.Locked || now.Before(user.LockedUntil)
+}
+
+// lockoutDuration returns how long an account with the given number of
+// consecutive failures stays locked. The window doubles with every failure
+// past the threshold, up to the configured maximum.
+func (s *authService) lockoutDuration(failures int) time.Duration {
+	d := s.lockoutBaseDuration
+	for i := s.lockoutThreshold; i < failures && d < s.lockoutMaxDuration; i++ {
+		d *= 2
+	}
+	if d > s.lockoutMaxDuration {
+		d = s.lockoutMaxDuration
+	}
+	return d
+}
+
+// registerFailedLogin counts a wrong password against the account and locks
+// it once the thresholds are reached. The counter lives in Mongo so that it
+// survives a Redis flush. Each source only counts lockoutSourceFailures times
+// per lockoutSourceWindow against an account, so a single client cannot lock
+// someone else out for good. Errors are logged rather than returned because
+// the login already failed.
+func (s *authService) registerFailedLogin(ctx context.Context, user *model.User) {
+	if s.lockoutSourceFailures > 0 && s.lockoutSourceWindow > 0 {
+		ip, _ := utils.ClientInfo(ctx)
+		key := fmt.Sprintf("login_failures:%s:%s", user.ID.Hex(), ip)
+		counted, err := utils.RateLimit(ctx, key, s.lockoutSourceFailures, s.lockoutSourceWindow)
+		if err != nil {
+			log.Printf("rate limiting failed logins for %s failed: %v", user.ID.Hex(), err)
+		} else if !counted {
+			return
+		}
+	}
+
+	failures, err := repository.RecordFailedLogin(ctx, user.ID)
+	if err != nil {
+		log.Printf("recording failed login for %s failed: %v", user.ID.Hex(), err)
+		return
+	}
+
+	switch {
+	case s.lockoutPermanentThreshold > 0 && failures >= s.lockoutPermanentThreshold:
+		if err := repository.LockUser(ctx, user.ID, time.Time{}, true); err != nil {
+			log.Printf("locking %s failed: %v", user.ID.Hex(), err)
+			return
+		}
+		if err := s.sendUnlockEmail(ctx, user); err != nil {
+			log.Printf("unlock email for %s failed: %v", user.ID.Hex(), err)
+		}
+	case s.lockoutThreshold > 0 && failures >= s.lockoutThreshold:
+		until := time.Now().Add(s.lockoutDuration(failures))
+		if err := repository.LockUser(ctx, user.ID, until, false); err != nil {
+			log.Printf("locking %s failed: %v", user.ID.Hex(), err)
+		}
+	}
+}
+
+// clearFailedLogins resets the failure counter after a successful login.
+func (s *authService) clearFailedLogins(ctx context.Context, user *model.User) {
+	if user.FailedLoginAttempts == 0 && user.LockedUntil.IsZero() {
+		return
+	}
+	if err := repository.ClearLockout(ctx, user.ID); err != nil {
+		log.Printf("clearing failed logins for %s failed: %v", user.ID.Hex(), err)
+	}
+}
+
+// RequestAccountUnlock emails an unlock link if the account is locked. It
+// responds the same way whether or not the account exists or is locked.
+func (s *authService) RequestAccountUnlock(ctx context.Context, email string) error {
+	if email == "" {
+		return ErrInvalidArgument
+	}
+
+	email, err := utils.NormalizeEmail(email)
+	if err != nil {
+		return ErrInvalidArgument
+	}
+
+	key := fmt.Sprintf("account_unlock:%s", email)
+	allowed, err := utils.RateLimit(ctx, key, 3, time.Hour)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return nil
+	}
+
+	user, err := repository.GetUserByEmail(email)
+	if err != nil || user == nil || !isLocked(user, time.Now()) {
+		return nil
+	}
+
+	if err := s.sendUnlockEmail(ctx, user); err != nil {
+		log.Printf("unlock email for %s failed: %v", user.ID.Hex(), err)
+	}
+	return nil
+}
+
+func (s *authService) sendUnlockEmail(ctx context.Context, user *model.User) error {
+	token, err := s.issueActionToken(ctx, user.ID, model.TokenPurposeAccountUnlock, s.accountUnlockTTL)
+	if err != nil {
+		return err
+	}
+
+	link, err := buildLink(s.accountUnlockURL, token)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account has been locked",
+		Body: fmt.Sprintf("Hi %s,\n\nYour account was locked after too many failed login attempts. Use the link below to unlock it. It expires in %s and can only be used once.\n\n%s\n\nIf these attempts were not yours, consider changing your password after unlocking.\n",
+			user.Name, s.accountUnlockTTL, link),
+	})
+}
+
+// ConfirmAccountUnlock lifts the lock with a token from an unlock email.
+func (s *authService) ConfirmAccountUnlock(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidArgument
+	}
+
+	actionToken, err := repository.ConsumeActionToken(ctx, utils.HashToken(token), model.TokenPurposeAccountUnlock)
+	if err != nil {
+		return ErrInvalidArgument
+	}
+
+	return repository.ClearLockout(ctx, actionToken.UserID)
+}
+
+// UnlockAccount lets an admin lift a temporary or permanent lock and reset
+// the failure counter.
+func (s *authService) UnlockAccount(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) error {
+	user, err := s.GetUserByID(ctx, targetUserID)
+	if err != nil {
+		return err
+	}
+
+	if err := repository.ClearLockout(ctx, targetUserID); err != nil {
+		return err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, targetUserID, model.AuditActionUnlockAccount, map[string]interface{}{
+		"failed_login_attempts": user.FailedLoginAttempts,
+		"permanent":             user.Locked,
+	})
+	return nil
+}
+
+func (s *authService) ListLockedAccounts(ctx context.Context, page, limit int64) ([]*model.User, int64, error) {
+	return repository.ListLockedUsers(ctx, page, limit)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	s := &authService{
+		lockoutThreshold:    5,
+		lockoutBaseDuration: time.Minute,
+		lockoutMaxDuration:  10 * time.Minute,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{8, 8 * time.Minute},
+		{9, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := s.lockoutDuration(tt.failures); got != tt.want {
+			t.Errorf("lockoutDuration(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLockoutDurationBaseAboveMax(t *testing.T) {
+	s := &authService{
+		lockoutThreshold:    3,
+		lockoutBaseDuration: time.Hour,
+		lockoutMaxDuration:  30 * time.Minute,
+	}
+	if got := s.lockoutDuration(3); got != 30*time.Minute {
+		t.Errorf("lockoutDuration(3) = %s, want the 30m maximum", got)
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"auth-microservice/internal/utils"
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func validPolicyType(policyType string) bool {
+	return policyType == model.PolicyTypeTermsOfService || policyType == model.PolicyTypePrivacyPolicy
+}
+
+// PublishPolicy makes a new version of a policy the current one. Publishing
+// a mandatory version requires every user to accept it again.
+func (s *authService) PublishPolicy(ctx context.Context, adminUserID primitive.ObjectID, policy *model.PolicyDocument) error {
+	policy.Version = strings.TrimSpace(policy.Version)
+	policy.Title = strings.TrimSpace(policy.Title)
+	policy.URL = strings.TrimSpace(policy.URL)
+
+	var violations []FieldViolation
+	if !validPolicyType(policy.Type) {
+		violations = append(violations, FieldViolation{Field: "type", Reason: "INVALID_POLICY_TYPE",
+			Description: "type must be terms_of_service or privacy_policy"})
+	}
+	if policy.Version == "" {
+		violations = append(violations, FieldViolation{Field: "version", Reason: "REQUIRED", Description: "a version is required"})
+	}
+	if policy.Title == "" {
+		violations = append(violations, FieldViolation{Field: "title", Reason: "REQUIRED", Description: "a title is required"})
+	}
+	if policy.URL == "" && strings.TrimSpace(policy.Content) == "" {
+		violations = append(violations, FieldViolation{Field: "content", Reason: "REQUIRED", Description: "either content or url is required"})
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+
+	policy.PublishedBy = adminUserID
+	err := repository.CreatePolicy(ctx, policy)
+	if mongo.IsDuplicateKeyError(err) {
+		return newFieldError("version", "VERSION_EXISTS", "this version has already been published")
+	}
+	if err != nil {
+		return err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, primitive.NilObjectID, model.AuditActionPublishPolicy, map[string]interface{}{
+		"type":      policy.Type,
+		"version":   policy.Version,
+		"mandatory": policy.Mandatory,
+	})
+	return nil
+}
+
+// CurrentPolicies returns the current version of each policy type.
+func (s *authService) CurrentPolicies(ctx context.Context) ([]model.PolicyDocument, error) {
+	return repository.LatestPolicies(ctx, false)
+}
+
+func (s *authService) ListPolicyVersions(ctx context.Context, policyType string) ([]model.PolicyDocument, error) {
+	if !validPolicyType(policyType) {
+		return nil, newFieldError("type", "INVALID_POLICY_TYPE", "type must be terms_of_service or privacy_policy")
+	}
+
+	return repository.ListPolicyVersions(ctx, policyType)
+}
+
+// resolveAcceptedPolicies checks that accepted names the current version of
+// each type it mentions and, when requireMandatory is set, that it covers
+// every type with a mandatory version.
+func (s *authService) resolveAcceptedPolicies(ctx context.Context, accepted []model.PolicyVersion, requireMandatory bool) ([]model.PolicyDocument, error) {
+	current, err := repository.LatestPolicies(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	currentByType := make(map[string]model.PolicyDocument, len(current))
+	for _, policy := range current {
+		currentByType[policy.Type] = policy
+	}
+
+	var violations []FieldViolation
+	var policies []model.PolicyDocument
+	seen := map[string]bool{}
+	for i, a := range accepted {
+		field := fmt.Sprintf("accepted_policies[%d]", i)
+		policy, ok := currentByType[a.Type]
+		switch {
+		case !ok:
+			violations = append(violations, FieldViolation{Field: field, Reason: "UNKNOWN_POLICY",
+				Description: fmt.Sprintf("no %q policy has been published", a.Type)})
+		case policy.Version != a.Version:
+			violations = append(violations, FieldViolation{Field: field, Reason: "POLICY_OUTDATED",
+				Description: fmt.Sprintf("the current %s version is %q", a.Type, policy.Version)})
+		case !seen[a.Type]:
+			seen[a.Type] = true
+			policies = append(policies, policy)
+		}
+	}
+
+	if requireMandatory {
+		mandatory, err := repository.LatestPolicies(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, policy := range mandatory {
+			if !seen[policy.Type] {
+				violations = append(violations, FieldViolation{Field: "accepted_policies", Reason: "POLICY_NOT_ACCEPTED",
+					Description: fmt.Sprintf("the %s must be accepted", policy.Type)})
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return nil, &ValidationError{Violations: violations}
+	}
+	return policies, nil
+}
+
+func (s *authService) recordPolicyAcceptances(ctx context.Context, userID primitive.ObjectID, policies []model.PolicyDocument) error {
+	ip, userAgent := utils.ClientInfo(ctx)
+	for i := range policies {
+		err := repository.RecordPolicyAcceptance(ctx, &model.PolicyAcceptance{
+			UserID:    userID,
+			IP:        ip,
+			UserAgent: userAgent,
+		}, &policies[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AcceptPolicies records that the user accepted the current versions of the
+// given policies.
+func (s *authService) AcceptPolicies(ctx context.Context, userID primitive.ObjectID, accepted []model.PolicyVersion) error {
+	if len(accepted) == 0 {
+		return newFieldError("accepted_policies", "REQUIRED", "at least one policy must be accepted")
+	}
+
+	policies, err := s.resolveAcceptedPolicies(ctx, accepted, false)
+	if err != nil {
+		return err
+	}
+
+	return s.recordPolicyAcceptances(ctx, userID, policies)
+}
+
+// PendingPolicies returns the mandatory policies the user still has to
+// accept.
+func (s *authService) PendingPolicies(ctx context.Context, userID primitive.ObjectID) ([]model.PolicyDocument, error) {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	mandatory, err := repository.LatestPolicies(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []model.PolicyDocument{}
+	for _, policy := range mandatory {
+		accepted, ok := user.AcceptedPolicies[policy.Type]
+		if !ok || accepted.PublishedAt.Before(policy.PublishedAt) {
+			pending = append(pending, policy)
+		}
+	}
+	return pending, nil
+}
+
+func (s *authService) ListPolicyAcceptances(ctx context.Context, targetUserID primitive.ObjectID) ([]model.PolicyAcceptance, error) {
+	return repository.ListUserPolicyAcceptances(ctx, targetUserID)
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// validatePermissions reports unknown permissions and returns the list
+// without duplicates.
+func validatePermissions(permissions []string) ([]string, error) {
+	var violations []FieldViolation
+	result := []string{}
+	seen := map[string]bool{}
+	for i, p := range permissions {
+		if !model.IsPermission(p) {
+			violations = append(violations, FieldViolation{
+				Field:       fmt.Sprintf("permissions[%d]", i),
+				Reason:      "UNKNOWN_PERMISSION",
+				Description: fmt.Sprintf("%q is not a known permission", p),
+			})
+			continue
+		}
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	if len(violations) > 0 {
+		return nil, &ValidationError{Violations: violations}
+	}
+	return result, nil
+}
+
+func (s *authService) CreateRole(ctx context.Context, adminUserID primitive.ObjectID, role *model.Role) error {
+	role.Name = strings.TrimSpace(role.Name)
+	if !roleNamePattern.MatchString(role.Name) {
+		return newFieldError("name", "INVALID_NAME",
+			"name must be 2 to 32 lowercase letters, digits, '_' or '-', starting with a letter")
+	}
+	permissions, err := validatePermissions(role.Permissions)
+	if err != nil {
+		return err
+	}
+	role.Permissions = permissions
+	role.Description = strings.TrimSpace(role.Description)
+	role.BuiltIn = false
+
+	err = repository.CreateRole(ctx, role)
+	if mongo.IsDuplicateKeyError(err) {
+		return newFieldError("name", "ROLE_EXISTS", "a role with this name already exists")
+	}
+	if err != nil {
+		return err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, primitive.NilObjectID, model.AuditActionCreateRole, map[string]interface{}{
+		"name":        role.Name,
+		"permissions": role.Permissions,
+	})
+	return nil
+}
+
+// UpdateRole replaces the description and permissions of a role. The admin
+// role always holds every permission and cannot be changed.
+func (s *authService) UpdateRole(ctx context.Context, adminUserID primitive.ObjectID, name, description string, permissions []string) (*model.Role, error) {
+	if name == model.RoleAdmin {
+		return nil, newFieldError("name", "BUILT_IN_ROLE", "the admin role cannot be changed")
+	}
+	current, err := repository.GetRoleByName(ctx, name)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err = validatePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+	if current.HasPermission(model.PermissionRolesManage) && !containsString(permissions, model.PermissionRolesManage) {
+		if err := s.ensureManagersRemainWithout(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+
+	role, err := repository.UpdateRole(ctx, name, strings.TrimSpace(description), permissions)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, primitive.NilObjectID, model.AuditActionUpdateRole, map[string]interface{}{
+		"name": name,
+		"from": current.Permissions,
+		"to":   role.Permissions,
+	})
+	return role, nil
+}
+
+// ensureManagersRemainWithout refuses to take roles.manage away from a role
+// if no active user would hold it afterwards.
+func (s *authService) ensureManagersRemainWithout(ctx context.Context, roleName string) error {
+	managers, err := repository.RoleNamesWithPermission(ctx, model.PermissionRolesManage)
+	if err != nil {
+		return err
+	}
+
+	var others []string
+	for _, m := range managers {
+		if m != roleName {
+			others = append(others, m)
+		}
+	}
+	if len(others) > 0 {
+		holders, err := repository.CountUsersWithRoles(ctx, others)
+		if err != nil {
+			return err
+		}
+		if holders > 0 {
+			return nil
+		}
+	}
+	return ErrLastAdmin
+}
+
+// DeleteRole removes a role that no user holds. Built-in roles cannot be
+// deleted.
+func (s *authService) DeleteRole(ctx context.Context, adminUserID primitive.ObjectID, name string) error {
+	role, err := repository.GetRoleByName(ctx, name)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return newFieldError("name", "BUILT_IN_ROLE", "built-in roles cannot be deleted")
+	}
+
+	holders, err := repository.CountRoleHolders(ctx, name)
+	if err != nil {
+		return err
+	}
+	if holders > 0 {
+		return ErrRoleInUse
+	}
+
+	err = repository.DeleteRole(ctx, name)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	s.recordAdminAction(ctx, adminUserID, primitive.NilObjectID, model.AuditActionDeleteRole, map[string]interface{}{
+		"name":        name,
+		"permissions": role.Permissions,
+	})
+	return nil
+}
+
+func (s *authService) ListRoles(ctx context.Context) ([]model.Role, error) {
+	return repository.ListRoles(ctx)
+}
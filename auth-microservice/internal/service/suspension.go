@@ -0,0 +1,75 @@
+package service
+
+import (
+	"auth-microservice/internal/model"
+	"auth-microservice/internal/repository"
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SuspendUser blocks the target from logging in and revokes their sessions.
+// A zero until suspends the account indefinitely.
+func (s *authService) SuspendUser(ctx context.Context, adminUserID, targetUserID primitive.ObjectID, reason string, until time.Time) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return newFieldError("reason", "REQUIRED", "a suspension reason is required")
+	}
+	if !until.IsZero() && !until.After(time.Now()) {
+		return newFieldError("until", "IN_PAST", "the suspension end time must be in the future")
+	}
+	if adminUserID == targetUserID {
+		return newFieldError("user_id", "SELF_SUSPENSION", "admins cannot suspend their own account")
+	}
+
+	err := repository.SuspendUser(ctx, targetUserID, adminUserID, reason, until)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := repository.RevokeSuspendedUserSessions(ctx, targetUserID); err != nil {
+		return err
+	}
+
+	details := map[string]interface{}{"reason": reason}
+	if !until.IsZero() {
+		details["until"] = until
+	}
+	err = repository.RecordAudit(ctx, &model.AuditEntry{
+		ActorID:      adminUserID,
+		Action:       model.AuditActionSuspendUser,
+		TargetUserID: targetUserID,
+		Details:      details,
+	})
+	if err != nil {
+		log.Printf("audit %s for %s failed: %v", model.AuditActionSuspendUser, targetUserID.Hex(), err)
+	}
+	return nil
+}
+
+func (s *authService) UnsuspendUser(ctx context.Context, adminUserID, targetUserID primitive.ObjectID) error {
+	err := repository.UnsuspendUser(ctx, targetUserID)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	err = repository.RecordAudit(ctx, &model.AuditEntry{
+		ActorID:      adminUserID,
+		Action:       model.AuditActionUnsuspendUser,
+		TargetUserID: targetUserID,
+	})
+	if err != nil {
+		log.Printf("audit %s for %s failed: %v", model.AuditActionUnsuspendUser, targetUserID.Hex(), err)
+	}
+	return nil
+}
@@ -5,15 +5,39 @@ import (
 	"os"
 	"time"
 
+	"auth-microservice/pkg/authz"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
 var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
 
-func GenerateJWT(userID string) (string, error) {
+const (
+	AccessTokenTTL     = time.Hour * 24
+	RestrictedTokenTTL = time.Minute * 15
+)
+
+// Restricted token scopes. Tokens without a scope grant full access.
+const (
+	ScopePasswordChange  = authz.ScopePasswordChange
+	ScopeUnverifiedEmail = authz.ScopeUnverifiedEmail
+)
+
+type AccessClaims struct {
+	UserID    string
+	SessionID string
+	Scope     string
+	ExpiresAt time.Time
+}
+
+func GenerateJWT(userID, sessionID, scope string, expiresAt time.Time) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"sid":     sessionID,
+		"exp":     expiresAt.Unix(),
+	}
+	if scope != "" {
+		claims["scope"] = scope
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -58,9 +82,9 @@ func ParseJWT(tokenStr string) (string, map[string]interface{}, error) {
 	return userID, claims, nil
 }
 
-func ExtractUserIDFromJWT(tokenString string) (string, error) {
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
 	if tokenString == "" {
-		return "", errors.New("token is empty")
+		return nil, errors.New("token is empty")
 	}
 
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -69,23 +93,36 @@ func ExtractUserIDFromJWT(tokenString string) (string, error) {
 		}
 		return jwtSecret, nil
 	})
-
 	if err != nil || !token.Valid {
-		return "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", errors.New("cannot parse claims")
+		return nil, errors.New("cannot parse claims")
 	}
 
 	userID, ok := claims["user_id"].(string)
-	if !ok {
-		userID, ok = claims["sub"].(string)
-		if !ok {
-			return "", errors.New("user_id not found in token claims")
-		}
+	if !ok || userID == "" {
+		return nil, errors.New("user_id not found in token claims")
+	}
+
+	sessionID, ok := claims["sid"].(string)
+	if !ok || sessionID == "" {
+		return nil, errors.New("sid not found in token claims")
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, errors.New("exp not found in token claims")
 	}
 
-	return userID, nil
+	scope, _ := claims["scope"].(string)
+
+	return &AccessClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Scope:     scope,
+		ExpiresAt: exp.Time,
+	}, nil
 }
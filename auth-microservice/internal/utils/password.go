@@ -1,25 +1,201 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"math"
+	"strings"
 
+	"auth-microservice/internal/config"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// BcryptMaxPasswordBytes is the longest password bcrypt accepts.
+const BcryptMaxPasswordBytes = 72
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// PasswordHasher hashes new passwords with the configured algorithm and
+// verifies hashes produced by any supported algorithm. Verify reports whether
+// a matching hash was produced with outdated settings and should be replaced.
+// MaxPasswordBytes is the longest password in bytes Hash accepts, or 0 if
+// there is no limit.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (match bool, needsRehash bool)
+	MaxPasswordBytes() int
+}
+
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type passwordHasher struct {
+	algorithm  string
+	argon2     Argon2Params
+	bcryptCost int
+}
+
+func NewPasswordHasher(algorithm string, argon2Params Argon2Params, bcryptCost int) (PasswordHasher, error) {
+	switch algorithm {
+	case AlgorithmArgon2id, AlgorithmBcrypt:
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", algorithm)
+	}
+	if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if argon2Params.Memory == 0 || argon2Params.Iterations == 0 || argon2Params.Parallelism == 0 ||
+		argon2Params.SaltLength == 0 || argon2Params.KeyLength == 0 {
+		return nil, errors.New("argon2 parameters must be positive")
+	}
+
+	return &passwordHasher{
+		algorithm:  algorithm,
+		argon2:     argon2Params,
+		bcryptCost: bcryptCost,
+	}, nil
+}
+
+// NewPasswordHasherFromEnv builds the hasher from PASSWORD_HASH_ALGORITHM,
+// ARGON2_* and BCRYPT_COST, rejecting values that do not fit the parameters.
+func NewPasswordHasherFromEnv() (PasswordHasher, error) {
+	params := Argon2Params{}
+	for _, p := range []struct {
+		name   string
+		def    int
+		max    int
+		target func(int)
+	}{
+		{"ARGON2_MEMORY_KIB", 64 * 1024, math.MaxUint32, func(v int) { params.Memory = uint32(v) }},
+		{"ARGON2_ITERATIONS", 3, math.MaxUint32, func(v int) { params.Iterations = uint32(v) }},
+		{"ARGON2_PARALLELISM", 2, math.MaxUint8, func(v int) { params.Parallelism = uint8(v) }},
+		{"ARGON2_SALT_LENGTH", 16, math.MaxUint32, func(v int) { params.SaltLength = uint32(v) }},
+		{"ARGON2_KEY_LENGTH", 32, math.MaxUint32, func(v int) { params.KeyLength = uint32(v) }},
+	} {
+		v := config.Int(p.name, p.def)
+		if v < 1 || v > p.max {
+			return nil, fmt.Errorf("%s must be between 1 and %d, got %d", p.name, p.max, v)
+		}
+		p.target(v)
+	}
+
+	return NewPasswordHasher(
+		config.String("PASSWORD_HASH_ALGORITHM", AlgorithmArgon2id),
+		params,
+		config.Int("BCRYPT_COST", bcrypt.DefaultCost),
+	)
+}
+
+func (h *passwordHasher) Hash(password string) (string, error) {
+	if h.algorithm == AlgorithmBcrypt {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		return string(bytes), err
+	}
+
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.argon2.Iterations, h.argon2.Memory, h.argon2.Parallelism, h.argon2.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.argon2.Memory, h.argon2.Iterations, h.argon2.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *passwordHasher) MaxPasswordBytes() int {
+	if h.algorithm == AlgorithmBcrypt {
+		return BcryptMaxPasswordBytes
+	}
+	return 0
+}
+
+func (h *passwordHasher) Verify(password, encoded string) (bool, bool) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2Hash(encoded)
+		if err != nil {
+			return false, false
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, false
+		}
+		return true, h.algorithm != AlgorithmArgon2id || params != h.argon2
+
+	case isBcryptHash(encoded):
+		if bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return true, h.algorithm != AlgorithmBcrypt || err != nil || cost != h.bcryptCost
+	}
+
+	return false, false
 }
 
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// IsSupportedPasswordHash reports whether encoded is a well-formed hash that
+// PasswordHasher.Verify understands, e.g. one imported from another system.
+func IsSupportedPasswordHash(encoded string) bool {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		_, _, _, err := decodeArgon2Hash(encoded)
+		return err == nil
+	case isBcryptHash(encoded):
+		_, err := bcrypt.Cost([]byte(encoded))
+		return err == nil && len(encoded) == 60
+	}
+	return false
+}
+
+func isBcryptHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
 }
 
-func ValidatePasswordResetToken(token string) (string, error) {
-	userID, err := ExtractUserIDFromJWT(token)
+func decodeArgon2Hash(encoded string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
-		return "", errors.New("invalid or expired password reset token")
+		return params, nil, nil, ErrUnknownHashFormat
 	}
-	return userID, nil
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
 }
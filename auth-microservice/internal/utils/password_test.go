@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// testArgon2 keeps the tests fast; production values come from ARGON2_*.
+var testArgon2 = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func newTestHasher(t *testing.T, algorithm string, params Argon2Params, bcryptCost int) PasswordHasher {
+	t.Helper()
+	h, err := NewPasswordHasher(algorithm, params, bcryptCost)
+	if err != nil {
+		t.Fatalf("NewPasswordHasher: %v", err)
+	}
+	return h
+}
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		prefix    string
+	}{
+		{AlgorithmArgon2id, "$argon2id$"},
+		{AlgorithmBcrypt, "$2a$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			h := newTestHasher(t, tt.algorithm, testArgon2, 4)
+
+			encoded, err := h.Hash("Grid-Whiz7pine")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if !strings.HasPrefix(encoded, tt.prefix) {
+				t.Errorf("Hash = %q, want prefix %q", encoded, tt.prefix)
+			}
+			if !IsSupportedPasswordHash(encoded) {
+				t.Errorf("IsSupportedPasswordHash(%q) = false", encoded)
+			}
+
+			if match, rehash := h.Verify("Grid-Whiz7pine", encoded); !match || rehash {
+				t.Errorf("Verify(right password) = %v, %v, want true, false", match, rehash)
+			}
+			if match, _ := h.Verify("grid-whiz7pine", encoded); match {
+				t.Error("Verify(wrong password) matched")
+			}
+		})
+	}
+}
+
+func TestPasswordHasherNeedsRehash(t *testing.T) {
+	stronger := testArgon2
+	stronger.Iterations = 2
+
+	tests := []struct {
+		name     string
+		hashWith PasswordHasher
+		verifier PasswordHasher
+		want     bool
+	}{
+		{"same argon2 settings", newTestHasher(t, AlgorithmArgon2id, testArgon2, 4), newTestHasher(t, AlgorithmArgon2id, testArgon2, 4), false},
+		{"changed argon2 settings", newTestHasher(t, AlgorithmArgon2id, testArgon2, 4), newTestHasher(t, AlgorithmArgon2id, stronger, 4), true},
+		{"same bcrypt cost", newTestHasher(t, AlgorithmBcrypt, testArgon2, 4), newTestHasher(t, AlgorithmBcrypt, testArgon2, 4), false},
+		{"changed bcrypt cost", newTestHasher(t, AlgorithmBcrypt, testArgon2, 4), newTestHasher(t, AlgorithmBcrypt, testArgon2, 5), true},
+		{"bcrypt to argon2", newTestHasher(t, AlgorithmBcrypt, testArgon2, 4), newTestHasher(t, AlgorithmArgon2id, testArgon2, 4), true},
+		{"argon2 to bcrypt", newTestHasher(t, AlgorithmArgon2id, testArgon2, 4), newTestHasher(t, AlgorithmBcrypt, testArgon2, 4), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tt.hashWith.Hash("Grid-Whiz7pine")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			match, rehash := tt.verifier.Verify("Grid-Whiz7pine", encoded)
+			if !match {
+				t.Fatal("Verify did not match")
+			}
+			if rehash != tt.want {
+				t.Errorf("needsRehash = %v, want %v", rehash, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPasswordHasherRejectsBadSettings(t *testing.T) {
+	tests := []struct {
+		name       string
+		algorithm  string
+		params     Argon2Params
+		bcryptCost int
+	}{
+		{"unknown algorithm", "md5", testArgon2, 10},
+		{"bcrypt cost too low", AlgorithmBcrypt, testArgon2, 1},
+		{"bcrypt cost too high", AlgorithmBcrypt, testArgon2, 32},
+		{"zero argon2 parameter", AlgorithmArgon2id, Argon2Params{Memory: 1024, Iterations: 1, SaltLength: 16, KeyLength: 32}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPasswordHasher(tt.algorithm, tt.params, tt.bcryptCost); err == nil {
+				t.Error("NewPasswordHasher succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestMaxPasswordBytes(t *testing.T) {
+	bcryptHasher := newTestHasher(t, AlgorithmBcrypt, testArgon2, 4)
+	if got := bcryptHasher.MaxPasswordBytes(); got != BcryptMaxPasswordBytes {
+		t.Errorf("bcrypt MaxPasswordBytes = %d, want %d", got, BcryptMaxPasswordBytes)
+	}
+	if _, err := bcryptHasher.Hash(strings.Repeat("a", BcryptMaxPasswordBytes)); err != nil {
+		t.Errorf("bcrypt Hash at the limit: %v", err)
+	}
+	if _, err := bcryptHasher.Hash(strings.Repeat("a", BcryptMaxPasswordBytes+1)); err == nil {
+		t.Error("bcrypt Hash over the limit succeeded")
+	}
+
+	if got := newTestHasher(t, AlgorithmArgon2id, testArgon2, 4).MaxPasswordBytes(); got != 0 {
+		t.Errorf("argon2id MaxPasswordBytes = %d, want 0", got)
+	}
+}
@@ -1,19 +1,42 @@
 package utils
 
-import "regexp"
+import (
+	"errors"
+	"regexp"
+	"strings"
 
+	"golang.org/x/net/idna"
+)
+
+var emailRegex = regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.([a-z]{2,}|xn--[a-z0-9\-]+)$`)
+
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// ValidEmail expects an address that has already been normalized with
+// NormalizeEmail.
 func ValidEmail(email string) bool {
-	regex := `^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}$`
-	re := regexp.MustCompile(regex)
-	return re.MatchString(email)
+	return emailRegex.MatchString(email)
 }
 
-func ValidPassword(password string) bool {
-	if len(password) < 8 {
-		return false
+// NormalizeEmail trims and lowercases an address and converts an
+// internationalized domain to its punycode form, so that every spelling of the
+// same mailbox is stored and looked up identically.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", ErrInvalidEmail
+	}
+
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(email[at+1:], "."))
+	if err != nil {
+		return "", ErrInvalidEmail
+	}
+
+	normalized := strings.ToLower(email[:at]) + "@" + strings.ToLower(domain)
+	if !ValidEmail(normalized) {
+		return "", ErrInvalidEmail
 	}
-	hasUpper := regexp.MustCompile(`[A-Z]`).MatchString(password)
-	hasLower := regexp.MustCompile(`[a-z]`).MatchString(password)
-	hasNumber := regexp.MustCompile(`[0-9]`).MatchString(password)
-	return hasUpper && hasLower && hasNumber
+	return normalized, nil
 }
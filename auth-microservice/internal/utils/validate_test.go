@@ -0,0 +1,40 @@
+package utils
+
+import "testing"
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email   string
+		want    string
+		wantErr bool
+	}{
+		{email: "user@example.com", want: "user@example.com"},
+		{email: "  User.Name+Tag@Example.COM ", want: "user.name+tag@example.com"},
+		{email: "user@example.com.", want: "user@example.com"},
+		{email: "user@bücher.de", want: "user@xn--bcher-kva.de"},
+		{email: "user@BÜCHER.de", want: "user@xn--bcher-kva.de"},
+		{email: "", wantErr: true},
+		{email: "user", wantErr: true},
+		{email: "@example.com", wantErr: true},
+		{email: "user@", wantErr: true},
+		{email: "user@localhost", wantErr: true},
+		{email: "us er@example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.email)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("NormalizeEmail(%q) = %q, want an error", tt.email, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeEmail(%q): %v", tt.email, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeEmail(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+}
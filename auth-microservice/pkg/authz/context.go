@@ -0,0 +1,34 @@
+package authz
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// contextKey keeps the values set by the interceptor from colliding with
+// keys of other packages.
+type contextKey int
+
+const (
+	userIDKey contextKey = iota
+	sessionIDKey
+)
+
+func withCaller(ctx context.Context, userID, sessionID primitive.ObjectID) context.Context {
+	ctx = context.WithValue(ctx, userIDKey, userID)
+	return context.WithValue(ctx, sessionIDKey, sessionID)
+}
+
+// UserID returns the id of the authenticated caller. It reports false for
+// public methods, which run without a caller.
+func UserID(ctx context.Context) (primitive.ObjectID, bool) {
+	id, ok := ctx.Value(userIDKey).(primitive.ObjectID)
+	return id, ok
+}
+
+// SessionID returns the id of the session the caller's token belongs to.
+func SessionID(ctx context.Context) (primitive.ObjectID, bool) {
+	id, ok := ctx.Value(sessionIDKey).(primitive.ObjectID)
+	return id, ok
+}
@@ -0,0 +1,182 @@
+package authz
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Restricted token scopes. Tokens without a scope grant full access.
+const (
+	ScopePasswordChange  = "password_change"
+	ScopeUnverifiedEmail = "unverified_email"
+)
+
+// Caller is the user a bearer token was issued to.
+type Caller struct {
+	UserID    primitive.ObjectID
+	SessionID primitive.ObjectID
+	// Scope restricts what the token may be used for, see ScopePasswordChange
+	// and ScopeUnverifiedEmail.
+	Scope string
+}
+
+// SessionChecker resolves a bearer token to its caller. It fails for tokens
+// that are invalid or whose session has ended. Errors carrying a gRPC status
+// are returned to the client as they are.
+type SessionChecker interface {
+	CheckSession(ctx context.Context, token string) (*Caller, error)
+}
+
+// PermissionChecker reports whether every one of permissions is granted to
+// the user. Errors carrying a gRPC status are returned to the client as they
+// are.
+type PermissionChecker interface {
+	HasPermissions(ctx context.Context, userID primitive.ObjectID, permissions []string) (bool, error)
+}
+
+// AcceptanceChecker reports whether the user has accepted every mandatory
+// policy version, such as the terms of service.
+type AcceptanceChecker interface {
+	HasAcceptedPolicies(ctx context.Context, userID primitive.ObjectID) (bool, error)
+}
+
+// Options holds the policies to enforce and the checks behind them.
+// Acceptance may be nil to skip checking policy acceptance.
+type Options struct {
+	Policies    Policies
+	Sessions    SessionChecker
+	Permissions PermissionChecker
+	Acceptance  AcceptanceChecker
+}
+
+// Authorizer enforces Policies on every call. Use its Unary and Stream
+// methods as the server's interceptors.
+type Authorizer struct {
+	policies    Policies
+	sessions    SessionChecker
+	permissions PermissionChecker
+	acceptance  AcceptanceChecker
+}
+
+func New(opts Options) *Authorizer {
+	return &Authorizer{
+		policies:    opts.Policies,
+		sessions:    opts.Sessions,
+		permissions: opts.Permissions,
+		acceptance:  opts.Acceptance,
+	}
+}
+
+func (a *Authorizer) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	newCtx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(newCtx, req)
+}
+
+func (a *Authorizer) Stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	newCtx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: newCtx})
+}
+
+// authorizedStream exposes the context carrying the caller's identity to
+// streaming handlers.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authorize enforces the policy of fullMethod and returns a context carrying
+// the caller's ids (see UserID and SessionID). Methods without a policy are
+// refused.
+func (a *Authorizer) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	policy, ok := a.policies[fullMethod]
+	if !ok {
+		log.Printf("refused %s: no authorization policy", fullMethod)
+		return nil, status.Errorf(codes.PermissionDenied, "no authorization policy for %s", fullMethod)
+	}
+	if policy.Public {
+		return ctx, nil
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing metadata")
+	}
+	authHeaders := md["authorization"]
+	if len(authHeaders) == 0 {
+		return nil, status.Errorf(codes.Unauthenticated, "authorization token not provided")
+	}
+	token := strings.TrimPrefix(authHeaders[0], "Bearer ")
+
+	caller, err := a.sessions.CheckSession(ctx, token)
+	if err != nil {
+		return nil, checkError("session", err)
+	}
+
+	if caller.Scope == ScopePasswordChange && !policy.AllowPasswordChange {
+		return nil, status.Errorf(codes.PermissionDenied, "password change required")
+	}
+	if caller.Scope == ScopeUnverifiedEmail && !policy.AllowUnverifiedEmail {
+		return nil, status.Errorf(codes.PermissionDenied, "email address has not been verified")
+	}
+
+	if a.acceptance != nil && !policy.AllowPendingPolicies {
+		accepted, err := a.acceptance.HasAcceptedPolicies(ctx, caller.UserID)
+		if err != nil {
+			return nil, checkError("policies", err)
+		}
+		if !accepted {
+			return nil, policyAcceptanceRequiredError()
+		}
+	}
+
+	if len(policy.Permissions) > 0 {
+		allowed, err := a.permissions.HasPermissions(ctx, caller.UserID, policy.Permissions)
+		if err != nil {
+			return nil, checkError("permissions", err)
+		}
+		if !allowed {
+			return nil, status.Errorf(codes.PermissionDenied, "forbidden")
+		}
+	}
+
+	return withCaller(ctx, caller.UserID, caller.SessionID), nil
+}
+
+// checkError passes errors that carry a gRPC status on and reports any other
+// as an internal error.
+func checkError(what string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "error checking %s: %v", what, err)
+}
+
+func policyAcceptanceRequiredError() error {
+	st := status.New(codes.FailedPrecondition, "updated policies must be accepted")
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: "POLICY_ACCEPTANCE_REQUIRED",
+		Domain: "auth",
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
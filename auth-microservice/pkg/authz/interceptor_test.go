@@ -0,0 +1,185 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeSessions resolves tokens to callers. Unknown tokens are rejected like
+// revoked sessions; the token "broken" fails without a status.
+type fakeSessions map[string]*Caller
+
+func (f fakeSessions) CheckSession(ctx context.Context, token string) (*Caller, error) {
+	if token == "broken" {
+		return nil, errors.New("database unavailable")
+	}
+	caller, ok := f[token]
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "session has been revoked")
+	}
+	return caller, nil
+}
+
+// fakePermissions grants each user the permissions listed for them.
+type fakePermissions map[primitive.ObjectID][]string
+
+func (f fakePermissions) HasPermissions(ctx context.Context, userID primitive.ObjectID, permissions []string) (bool, error) {
+	for _, want := range permissions {
+		granted := false
+		for _, p := range f[userID] {
+			granted = granted || p == want
+		}
+		if !granted {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// fakeAcceptance lists the users that have accepted every mandatory policy.
+type fakeAcceptance map[primitive.ObjectID]bool
+
+func (f fakeAcceptance) HasAcceptedPolicies(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	return f[userID], nil
+}
+
+func TestAuthorizerUnary(t *testing.T) {
+	admin := &Caller{UserID: primitive.NewObjectID(), SessionID: primitive.NewObjectID()}
+	member := &Caller{UserID: primitive.NewObjectID(), SessionID: primitive.NewObjectID()}
+	pending := &Caller{UserID: primitive.NewObjectID(), SessionID: primitive.NewObjectID()}
+	mustChange := &Caller{UserID: member.UserID, SessionID: primitive.NewObjectID(), Scope: ScopePasswordChange}
+	unverified := &Caller{UserID: member.UserID, SessionID: primitive.NewObjectID(), Scope: ScopeUnverifiedEmail}
+
+	authorizer := New(Options{
+		Policies: Policies{
+			"/test/Open":           {Public: true},
+			"/test/Profile":        {},
+			"/test/Write":          {Permissions: []string{"things.read", "things.write"}},
+			"/test/ChangePassword": {AllowPasswordChange: true},
+			"/test/Verify":         {AllowUnverifiedEmail: true},
+			"/test/Accept":         {AllowPendingPolicies: true},
+		},
+		Sessions: fakeSessions{
+			"admin":       admin,
+			"member":      member,
+			"pending":     pending,
+			"must-change": mustChange,
+			"unverified":  unverified,
+		},
+		Permissions: fakePermissions{
+			admin.UserID:  {"things.read", "things.write"},
+			member.UserID: {"things.read"},
+		},
+		Acceptance: fakeAcceptance{admin.UserID: true, member.UserID: true},
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		wantCode   codes.Code
+		wantCaller *Caller
+	}{
+		{"public without token", "/test/Open", "", codes.OK, nil},
+		{"no policy", "/test/Unknown", "admin", codes.PermissionDenied, nil},
+		{"missing token", "/test/Profile", "", codes.Unauthenticated, nil},
+		{"revoked session", "/test/Profile", "stolen", codes.Unauthenticated, nil},
+		{"session check failing", "/test/Profile", "broken", codes.Internal, nil},
+		{"authenticated", "/test/Profile", "member", codes.OK, member},
+		{"all permissions", "/test/Write", "admin", codes.OK, admin},
+		{"missing permission", "/test/Write", "member", codes.PermissionDenied, nil},
+		{"password change scope refused", "/test/Profile", "must-change", codes.PermissionDenied, nil},
+		{"password change scope allowed", "/test/ChangePassword", "must-change", codes.OK, mustChange},
+		{"unverified email scope refused", "/test/Profile", "unverified", codes.PermissionDenied, nil},
+		{"unverified email scope allowed", "/test/Verify", "unverified", codes.OK, unverified},
+		{"pending policies refused", "/test/Profile", "pending", codes.FailedPrecondition, nil},
+		{"pending policies allowed", "/test/Accept", "pending", codes.OK, pending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{})
+			if tt.token != "" {
+				ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tt.token))
+			}
+
+			var handlerCtx context.Context
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				handlerCtx = ctx
+				return "ok", nil
+			}
+			_, err := authorizer.Unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("Unary code = %s, want %s (err %v)", got, tt.wantCode, err)
+			}
+			if tt.wantCode != codes.OK {
+				if handlerCtx != nil {
+					t.Error("handler ran for a refused call")
+				}
+				return
+			}
+
+			userID, hasUser := UserID(handlerCtx)
+			sessionID, _ := SessionID(handlerCtx)
+			if tt.wantCaller == nil {
+				if hasUser {
+					t.Errorf("public method got caller %s", userID.Hex())
+				}
+				return
+			}
+			if userID != tt.wantCaller.UserID || sessionID != tt.wantCaller.SessionID {
+				t.Errorf("context caller = %s/%s, want %s/%s", userID.Hex(), sessionID.Hex(),
+					tt.wantCaller.UserID.Hex(), tt.wantCaller.SessionID.Hex())
+			}
+		})
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAuthorizerStream(t *testing.T) {
+	caller := &Caller{UserID: primitive.NewObjectID(), SessionID: primitive.NewObjectID()}
+	authorizer := New(Options{
+		Policies:    Policies{"/test/Import": {Permissions: []string{"things.write"}}},
+		Sessions:    fakeSessions{"writer": caller, "reader": {UserID: primitive.NewObjectID()}},
+		Permissions: fakePermissions{caller.UserID: {"things.write"}},
+	})
+	info := &grpc.StreamServerInfo{FullMethod: "/test/Import"}
+
+	var got primitive.ObjectID
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		got, _ = UserID(ss.Context())
+		return nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer writer"))
+	if err := authorizer.Stream(nil, &fakeStream{ctx: ctx}, info, handler); err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	if got != caller.UserID {
+		t.Errorf("stream context caller = %s, want %s", got.Hex(), caller.UserID.Hex())
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer reader"))
+	err := authorizer.Stream(nil, &fakeStream{ctx: ctx}, info, func(interface{}, grpc.ServerStream) error {
+		t.Error("handler ran for a refused stream")
+		return nil
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Stream code = %s, want %s", status.Code(err), codes.PermissionDenied)
+	}
+}
@@ -0,0 +1,192 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v6.31.1
+// source: authz/options.proto
+
+package authz
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// AuthPolicy declares what a caller needs to invoke an RPC. The interceptor
+// of the Go package auth-microservice/pkg/authz reads it from the service
+// descriptors at startup and refuses methods that do not have one. Without
+// public or permissions, any caller with a valid token may call the method.
+type AuthPolicy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The method can be called without a token.
+	Public bool `protobuf:"varint,1,opt,name=public,proto3" json:"public,omitempty"`
+	// Every one of these must be granted by the caller's roles.
+	Permissions []string `protobuf:"bytes,2,rep,name=permissions,proto3" json:"permissions,omitempty"`
+	// Tokens restricted to changing an expired password may call the method.
+	AllowPasswordChange bool `protobuf:"varint,3,opt,name=allow_password_change,json=allowPasswordChange,proto3" json:"allow_password_change,omitempty"`
+	// Tokens of users who have not verified their email may call the method.
+	AllowUnverifiedEmail bool `protobuf:"varint,4,opt,name=allow_unverified_email,json=allowUnverifiedEmail,proto3" json:"allow_unverified_email,omitempty"`
+	// The method can be called while a mandatory policy version is waiting for
+	// the user's acceptance.
+	AllowPendingPolicies bool `protobuf:"varint,5,opt,name=allow_pending_policies,json=allowPendingPolicies,proto3" json:"allow_pending_policies,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *AuthPolicy) Reset() {
+	*x = AuthPolicy{}
+	mi := &file_authz_options_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuthPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthPolicy) ProtoMessage() {}
+
+func (x *AuthPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_authz_options_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthPolicy.ProtoReflect.Descriptor instead.
+func (*AuthPolicy) Descriptor() ([]byte, []int) {
+	return file_authz_options_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AuthPolicy) GetPublic() bool {
+	if x != nil {
+		return x.Public
+	}
+	return false
+}
+
+func (x *AuthPolicy) GetPermissions() []string {
+	if x != nil {
+		return x.Permissions
+	}
+	return nil
+}
+
+func (x *AuthPolicy) GetAllowPasswordChange() bool {
+	if x != nil {
+		return x.AllowPasswordChange
+	}
+	return false
+}
+
+func (x *AuthPolicy) GetAllowUnverifiedEmail() bool {
+	if x != nil {
+		return x.AllowUnverifiedEmail
+	}
+	return false
+}
+
+func (x *AuthPolicy) GetAllowPendingPolicies() bool {
+	if x != nil {
+		return x.AllowPendingPolicies
+	}
+	return false
+}
+
+var file_authz_options_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*AuthPolicy)(nil),
+		Field:         50001,
+		Name:          "authz.policy",
+		Tag:           "bytes,50001,opt,name=policy",
+		Filename:      "authz/options.proto",
+	},
+}
+
+// Extension fields to descriptorpb.MethodOptions.
+var (
+	// optional authz.AuthPolicy policy = 50001;
+	E_Policy = &file_authz_options_proto_extTypes[0]
+)
+
+var File_authz_options_proto protoreflect.FileDescriptor
+
+const file_authz_options_proto_rawDesc = "" +
+	"\n" +
+	"\x13authz/options.proto\x12\x05authz\x1a google/protobuf/descriptor.proto\"\xe6\x01\n" +
+	"\n" +
+	"AuthPolicy\x12\x16\n" +
+	"\x06public\x18\x01 \x01(\bR\x06public\x12 \n" +
+	"\vpermissions\x18\x02 \x03(\tR\vpermissions\x122\n" +
+	"\x15allow_password_change\x18\x03 \x01(\bR\x13allowPasswordChange\x124\n" +
+	"\x16allow_unverified_email\x18\x04 \x01(\bR\x14allowUnverifiedEmail\x124\n" +
+	"\x16allow_pending_policies\x18\x05 \x01(\bR\x14allowPendingPolicies:K\n" +
+	"\x06policy\x12\x1e.google.protobuf.MethodOptions\x18ц\x03 \x01(\v2\x11.authz.AuthPolicyR\x06policyB#Z!auth-microservice/pkg/authz;authzb\x06proto3"
+
+var (
+	file_authz_options_proto_rawDescOnce sync.Once
+	file_authz_options_proto_rawDescData []byte
+)
+
+func file_authz_options_proto_rawDescGZIP() []byte {
+	file_authz_options_proto_rawDescOnce.Do(func() {
+		file_authz_options_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_authz_options_proto_rawDesc), len(file_authz_options_proto_rawDesc)))
+	})
+	return file_authz_options_proto_rawDescData
+}
+
+var file_authz_options_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_authz_options_proto_goTypes = []any{
+	(*AuthPolicy)(nil),                 // 0: authz.AuthPolicy
+	(*descriptorpb.MethodOptions)(nil), // 1: google.protobuf.MethodOptions
+}
+var file_authz_options_proto_depIdxs = []int32{
+	1, // 0: authz.policy:extendee -> google.protobuf.MethodOptions
+	0, // 1: authz.policy:type_name -> authz.AuthPolicy
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	1, // [1:2] is the sub-list for extension type_name
+	0, // [0:1] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_authz_options_proto_init() }
+func file_authz_options_proto_init() {
+	if File_authz_options_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_authz_options_proto_rawDesc), len(file_authz_options_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 1,
+			NumServices:   0,
+		},
+		GoTypes:           file_authz_options_proto_goTypes,
+		DependencyIndexes: file_authz_options_proto_depIdxs,
+		MessageInfos:      file_authz_options_proto_msgTypes,
+		ExtensionInfos:    file_authz_options_proto_extTypes,
+	}.Build()
+	File_authz_options_proto = out.File
+	file_authz_options_proto_goTypes = nil
+	file_authz_options_proto_depIdxs = nil
+}
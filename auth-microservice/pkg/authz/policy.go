@@ -0,0 +1,92 @@
+// Package authz authorizes gRPC calls according to the (authz.policy) option
+// declared on every RPC, see proto/authz/options.proto. How tokens, sessions
+// and permissions are checked is left to the service using it.
+package authz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Policy declares what a caller needs to invoke a gRPC method. It is read
+// from the (authz.policy) option of the method in the proto file.
+type Policy struct {
+	// Public methods can be called without a token.
+	Public bool
+	// Permissions must all be granted to the caller. Without any, every
+	// authenticated caller may call the method.
+	Permissions []string
+	// AllowPasswordChange lets tokens restricted to ScopePasswordChange call
+	// the method.
+	AllowPasswordChange bool
+	// AllowUnverifiedEmail lets tokens restricted to ScopeUnverifiedEmail
+	// call the method.
+	AllowUnverifiedEmail bool
+	// AllowPendingPolicies lets users call the method while a mandatory policy
+	// version is waiting for their acceptance.
+	AllowPendingPolicies bool
+}
+
+// Policies holds the policy of every method, keyed by the full method name
+// as in grpc.UnaryServerInfo.FullMethod.
+type Policies map[string]Policy
+
+// LoadPolicies reads the (authz.policy) option of every method of services
+// from the registered proto descriptors. isPermission reports whether a
+// permission can be granted at all. Methods without the option, or with a
+// permission isPermission rejects, are reported so the server refuses to
+// start rather than rejecting calls at runtime.
+func LoadPolicies(isPermission func(string) bool, services ...*grpc.ServiceDesc) (Policies, error) {
+	policies := Policies{}
+	var problems []string
+
+	for _, sd := range services {
+		desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(sd.ServiceName))
+		if err != nil {
+			return nil, fmt.Errorf("descriptor of service %s: %w", sd.ServiceName, err)
+		}
+		service, ok := desc.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a service", sd.ServiceName)
+		}
+
+		methods := service.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			method := methods.Get(i)
+			fullMethod := "/" + sd.ServiceName + "/" + string(method.Name())
+
+			opts, ok := method.Options().(*descriptorpb.MethodOptions)
+			if !ok || !proto.HasExtension(opts, E_Policy) {
+				problems = append(problems, fullMethod+": no (authz.policy) option")
+				continue
+			}
+			option := proto.GetExtension(opts, E_Policy).(*AuthPolicy)
+			for _, p := range option.Permissions {
+				if !isPermission(p) {
+					problems = append(problems, fmt.Sprintf("%s: unknown permission %q", fullMethod, p))
+				}
+			}
+
+			policies[fullMethod] = Policy{
+				Public:               option.Public,
+				Permissions:          option.Permissions,
+				AllowPasswordChange:  option.AllowPasswordChange,
+				AllowUnverifiedEmail: option.AllowUnverifiedEmail,
+				AllowPendingPolicies: option.AllowPendingPolicies,
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid authorization policies: %s", strings.Join(problems, "; "))
+	}
+	return policies, nil
+}
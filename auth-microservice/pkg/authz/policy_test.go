@@ -0,0 +1,121 @@
+package authz
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// registerTestService registers a service named authz.test.<name> whose
+// methods carry the given policies; a nil policy leaves the option out.
+func registerTestService(t *testing.T, name string, methods map[string]*AuthPolicy) *grpc.ServiceDesc {
+	t.Helper()
+
+	service := &descriptorpb.ServiceDescriptorProto{Name: proto.String(name)}
+	for method, policy := range methods {
+		opts := &descriptorpb.MethodOptions{}
+		if policy != nil {
+			proto.SetExtension(opts, E_Policy, policy)
+		}
+		service.Method = append(service.Method, &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(method),
+			InputType:  proto.String(".google.protobuf.Empty"),
+			OutputType: proto.String(".google.protobuf.Empty"),
+			Options:    opts,
+		})
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("authz/test/" + strings.ToLower(name) + ".proto"),
+		Package:    proto.String("authz.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/empty.proto", "authz/options.proto"},
+		Service:    []*descriptorpb.ServiceDescriptorProto{service},
+	}
+
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatalf("building descriptor: %v", err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+		t.Fatalf("registering descriptor: %v", err)
+	}
+	return &grpc.ServiceDesc{ServiceName: "authz.test." + name}
+}
+
+func isTestPermission(p string) bool {
+	return p == "things.read" || p == "things.write"
+}
+
+func TestLoadPolicies(t *testing.T) {
+	valid := registerTestService(t, "Valid", map[string]*AuthPolicy{
+		"Open":  {Public: true},
+		"Read":  {Permissions: []string{"things.read"}},
+		"Write": {Permissions: []string{"things.read", "things.write"}, AllowPendingPolicies: true},
+	})
+	missing := registerTestService(t, "Missing", map[string]*AuthPolicy{
+		"Annotated":   {},
+		"Unannotated": nil,
+	})
+	unknown := registerTestService(t, "Unknown", map[string]*AuthPolicy{
+		"Delete": {Permissions: []string{"things.read", "things.delete"}},
+	})
+
+	tests := []struct {
+		name     string
+		services []*grpc.ServiceDesc
+		want     Policies
+		wantErr  string
+	}{
+		{
+			name:     "valid",
+			services: []*grpc.ServiceDesc{valid},
+			want: Policies{
+				"/authz.test.Valid/Open":  {Public: true},
+				"/authz.test.Valid/Read":  {Permissions: []string{"things.read"}},
+				"/authz.test.Valid/Write": {Permissions: []string{"things.read", "things.write"}, AllowPendingPolicies: true},
+			},
+		},
+		{
+			name:     "missing option",
+			services: []*grpc.ServiceDesc{valid, missing},
+			wantErr:  `/authz.test.Missing/Unannotated: no (authz.policy) option`,
+		},
+		{
+			name:     "unknown permission",
+			services: []*grpc.ServiceDesc{unknown},
+			wantErr:  `/authz.test.Unknown/Delete: unknown permission "things.delete"`,
+		},
+		{
+			name:     "unknown service",
+			services: []*grpc.ServiceDesc{{ServiceName: "authz.test.Nope"}},
+			wantErr:  "descriptor of service authz.test.Nope",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadPolicies(isTestPermission, tt.services...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadPolicies error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				if got != nil {
+					t.Errorf("LoadPolicies returned policies along with an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadPolicies: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadPolicies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}